@@ -5,6 +5,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 type systemContract struct {
@@ -13,57 +14,50 @@ type systemContract struct {
 	packFun func() ([]byte, error)
 }
 
-func getIshikariSystemContracts(abi map[string]abi.ABI, initValidators []common.Address, managers []common.Address, admin common.Address, epoch *big.Int) []*systemContract {
+func getIshikariSystemContracts(abi map[string]abi.ABI, addrs map[string]common.Address, config *params.POSAConfig) []*systemContract {
 
-	feeShares := make([]*big.Int, len(initValidators))
-	for i := range initValidators {
-		feeShares[i] = big.NewInt(2000) // @cary initial fee share
-	}
+	var (
+		validatorsContract  = addrs[IshikariValidatorsContractName]
+		punishContract      = addrs[IshikariPunishContractName]
+		proposalContract    = addrs[IshikariProposalContractName]
+		reservePoolContract = addrs[IshikariReservePoolContractName]
+
+		admin = config.IshikariAdminMultiSig
+		epoch = new(big.Int).SetUint64(config.Epoch)
+	)
 
 	return []*systemContract{
 		{
 			// Ishikari Validators Contract
-			addr: IshikariValidatorsContractAddr,
-			code: IshikariValidatorsCode,
-			packFun: func() ([]byte, error) {
-				return abi[IshikariValidatorsContractName].Pack("initialize",
-					initValidators,
-					managers,
-					feeShares,
-					admin,
-					IshikariValidatorsContractAddr,
-					IshikariPunishContractAddr,
-					IshikariProposalAddr,
-					IshikariReservePoolAddr,
-					epoch,
-				)
-			},
+			addr:    validatorsContract,
+			code:    IshikariValidatorsCode,
+			packFun: config.GenesisValidatorSetBytes,
 		},
 		{
 			// Ishikari Proposal Contract
-			addr: IshikariProposalAddr,
+			addr: proposalContract,
 			code: IshikariProposalCode,
 			packFun: func() ([]byte, error) {
 				return abi[IshikariProposalContractName].Pack("initialize",
 					admin,
-					IshikariValidatorsContractAddr,
-					IshikariPunishContractAddr,
-					IshikariProposalAddr,
-					IshikariReservePoolAddr,
+					validatorsContract,
+					punishContract,
+					proposalContract,
+					reservePoolContract,
 					epoch,
 				)
 			},
 		},
 		{
 			// Ishikari Punish Contract
-			addr: IshikariPunishContractAddr,
+			addr: punishContract,
 			code: IshikariPunishCode,
 			packFun: func() ([]byte, error) {
 				return abi[IshikariPunishContractName].Pack("initialize",
-					IshikariValidatorsContractAddr,
-					IshikariPunishContractAddr,
-					IshikariProposalAddr,
-					IshikariReservePoolAddr,
+					validatorsContract,
+					punishContract,
+					proposalContract,
+					reservePoolContract,
 					admin,
 					epoch,
 				)
@@ -71,15 +65,15 @@ func getIshikariSystemContracts(abi map[string]abi.ABI, initValidators []common.
 		},
 		{
 			// Ishikari ReservePool Contract
-			addr: IshikariReservePoolAddr,
+			addr: reservePoolContract,
 			code: IshikariReservePoolCode,
 			packFun: func() ([]byte, error) {
 				return abi[IshikariReservePoolContractName].Pack("initialize",
 					admin,
-					IshikariValidatorsContractAddr,
-					IshikariPunishContractAddr,
-					IshikariProposalAddr,
-					IshikariReservePoolAddr,
+					validatorsContract,
+					punishContract,
+					proposalContract,
+					reservePoolContract,
 					epoch,
 				)
 			},
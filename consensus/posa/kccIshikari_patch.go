@@ -10,8 +10,8 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 )
 
-// each patch is a state mutating function
-type patch func(state *state.StateDB)
+// each patch is a state mutating function, given the system contract addresses
+type patch func(state *state.StateDB, addrs map[string]common.Address)
 
 // Ishikari patch001: fix minor issues found on testnet
 func getIshikariPatch001() []patch {
@@ -27,7 +27,7 @@ func getIshikariPatch001() []patch {
 //
 // These minor issues are fixed in the following patches
 
-func patch001ValidatorsContract(state *state.StateDB) {
+func patch001ValidatorsContract(state *state.StateDB, addrs map[string]common.Address) {
 
 	// source:
 	// checksum:
@@ -41,11 +41,11 @@ func patch001ValidatorsContract(state *state.StateDB) {
 	verifyMd5Sum(code, common.FromHex("0xcf5dec2eee8d6a3b362f97397753f4df"))
 
 	// Upgrade  codes
-	state.SetCode(IshikariValidatorsContractAddr, code)
+	state.SetCode(addrs[IshikariValidatorsContractName], code)
 
 }
 
-func patch001PunishContract(state *state.StateDB) {
+func patch001PunishContract(state *state.StateDB, addrs map[string]common.Address) {
 
 	code := common.FromHex("0x608060405234801561001057600080fd5b506004361061010b5760003560e01c80638f283970116100a2578063e0d8ea5311610071578063e0d8ea5314610237578063e5a99f4f1461023f578063ea7221a114610247578063f62af26c1461026d578063f851a4401461028a5761010b565b80638f283970146101b557806395b6ef0c146101db578063a0dc275814610227578063cb1ea7251461022f5761010b565b80635e81f1f8116100de5780635e81f1f81461017c57806360c80cbf14610186578063714897df1461018e578063863a20b7146101ad5761010b565b80632897183d1461011057806332f3c17f1461012a57806344c1aa991461015057806346f7513814610158575b600080fd5b610118610292565b60408051918252519081900360200190f35b6101186004803603602081101561014057600080fd5b50356001600160a01b0316610298565b6101186102b3565b6101606102b9565b604080516001600160a01b039092168252519081900360200190f35b6101846102c8565b005b610160610525565b610196610534565b6040805161ffff9092168252519081900360200190f35b610160610539565b610184600480360360208110156101cb57600080fd5b50356001600160a01b0316610548565b610184600480360360c08110156101f157600080fd5b506001600160a01b0381358116916020810135821691604082013581169160608101358216916080820135169060a001356105b9565b610118610686565b61011861068c565b610118610692565b610160610698565b6101846004803603602081101561025d57600080fd5b50356001600160a01b03166106a7565b6101606004803603602081101561028357600080fd5b5035610a0a565b610160610a31565b603b5481565b6001600160a01b03166000908152603c602052604090205490565b603a5481565b6033546001600160a01b031681565b334114610309576040805162461bcd60e51b815260206004820152600a6024820152694d696e6572206f6e6c7960b01b604482015290519081900360640190fd5b436000908152603f602052604090205460ff1615610363576040805162461bcd60e51b8152602060048201526012602482015271105b1c9958591e4817d91958dc99585cd95960721b604482015290519081900360640190fd5b603754438161036e57fe5b06156103b4576040805162461bcd60e51b815260206004820152601060248201526f426c6f636b2065706f6368206f6e6c7960801b604482015290519081900360640190fd5b436000908152603f60205260409020805460ff19166001179055603d546103da57610523565b60005b603d548110156104f857603b54603a54816103f457fe5b04603c6000603d848154811061040657fe5b60009182526020808320909101546001600160a01b0316835282019290925260400190205411156104b757603b54603a548161043e57fe5b04603c6000603d848154811061045057fe5b60009182526020808320909101546001600160a01b03168352820192909252604001812054603d80549390910392603c9291908590811061048d57fe5b60009182526020808320909101546001600160a01b031683528201929092526040019020556104f0565b6000603c6000603d84815481106104ca57fe5b60009182526020808320909101546001600160a01b031683528201929092526040019020555b6001016103dd565b506040517f181d51be54e8e8eaca6eae0eab32d4162099236bd519e7238d015d0870db464190600090a15b565b6036546001600160a01b031681565b601d81565b6035546001600160a01b031681565b6038546001600160a01b03163314610597576040805162461bcd60e51b815260206004820152600d60248201526c36bab9ba1031329030b236b4b760991b604482015290519081900360640190fd5b603880546001600160a01b0319166001600160a01b0392909216919091179055565b600054610100900460ff16806105d257506105d2610a40565b806105e0575060005460ff16155b61061b5760405162461bcd60e51b815260040180806020018281038252602e815260200180610d84602e913960400191505060405180910390fd5b600054610100900460ff16158015610646576000805460ff1961ff0019909116610100171660011790555b61064f83610a46565b61065c8787878786610b04565b601860398190556030603a55603b55801561067d576000805461ff00191690555b50505050505050565b60375481565b60395481565b603d5490565b6034546001600160a01b031681565b3341146106e8576040805162461bcd60e51b815260206004820152600a6024820152694d696e6572206f6e6c7960b01b604482015290519081900360640190fd5b436000908152603e602052604090205460ff1615610741576040805162461bcd60e51b8152602060048201526011602482015270105b1c9958591e4817dc1d5b9a5cda1959607a1b604482015290519081900360640190fd5b436000908152603e6020908152604091829020805460ff191660011790556033548251635671334360e01b81526001600160a01b03858116600483015293519390911692635671334392602480840193919291829003018186803b1580156107a857600080fd5b505afa1580156107bc573d6000803e3d6000fd5b505050506040513d60208110156107d257600080fd5b50516107dd57610a07565b6001600160a01b0381166000908152603c602052604090206002015460ff1661086e57603d80546001600160a01b0383166000818152603c6020526040812060018082018590558085019095557fece66cfdbd22e3f37d348a3d8e19074452862cd65fd4b9a11f0336d1ac6d1dc390930180546001600160a01b0319168317905552600201805460ff191690911790555b6001600160a01b0381166000908152603c60205260409020805460010190819055603a54908161089a57fe5b066109335760335460408051637c01f05360e01b81526001600160a01b0384811660048301526001602483015291519190921691637c01f05391604480830192600092919082900301818387803b1580156108f457600080fd5b505af1158015610908573d6000803e3d6000fd5b5050506001600160a01b0382166000908152603c60205260408120555061092e81610bfa565b6109c7565b6039546001600160a01b0382166000908152603c60205260409020548161095657fe5b066109c75760335460408051637c01f05360e01b81526001600160a01b0384811660048301526000602483018190529251931692637c01f0539260448084019391929182900301818387803b1580156109ae57600080fd5b505af11580156109c2573d6000803e3d6000fd5b505050505b6040805142815290516001600160a01b038316917f770e0cca42c35d00240986ce8d3ed438be04663c91dac6576b79537d7c180f1e919081900360200190a25b50565b603d8181548110610a1757fe5b6000918252602090912001546001600160a01b0316905081565b6038546001600160a01b031681565b303b1590565b600054610100900460ff1680610a5f5750610a5f610a40565b80610a6d575060005460ff16155b610aa85760405162461bcd60e51b815260040180806020018281038252602e815260200180610d84602e913960400191505060405180910390fd5b600054610100900460ff16158015610ad3576000805460ff1961ff0019909116610100171660011790555b603880546001600160a01b0319166001600160a01b0384161790558015610b00576000805461ff00191690555b5050565b600054610100900460ff1680610b1d5750610b1d610a40565b80610b2b575060005460ff16155b610b665760405162461bcd60e51b815260040180806020018281038252602e815260200180610d84602e913960400191505060405180910390fd5b600054610100900460ff16158015610b91576000805460ff1961ff0019909116610100171660011790555b603380546001600160a01b038089166001600160a01b0319928316179092556034805488841690831617905560358054878416908316179055603680549286169290911691909117905560378290558015610bf2576000805461ff00191690555b505050505050565b6001600160a01b0381166000908152603c602052604090205415610c32576001600160a01b0381166000908152603c60205260408120555b6001600160a01b0381166000908152603c602052604090206002015460ff168015610c5e5750603d5415155b15610a0757603d546001600160a01b0382166000908152603c602052604090206001015460001990910114610d2857603d8054600091906000198101908110610ca357fe5b60009182526020808320909101546001600160a01b038581168452603c909252604090922060010154603d80549290931693508392918110610ce157fe5b600091825260208083209190910180546001600160a01b0319166001600160a01b039485161790558483168252603c90526040808220600190810154949093168252902001555b603d805480610d3357fe5b60008281526020808220830160001990810180546001600160a01b03191690559092019092556001600160a01b0383168252603c9052604081206001810191909155600201805460ff191690555056fe436f6e747261637420696e7374616e63652068617320616c7265616479206265656e20696e697469616c697a6564a2646970667358221220d08e97ffea4ab48a59d6a8300e8a6d178aebf94651752cdc426a01943f551f2f64736f6c634300060c0033")
 
@@ -53,7 +53,7 @@ func patch001PunishContract(state *state.StateDB) {
 	verifyMd5Sum(code, common.FromHex("0xf6ab56760ef5897c4295e99e240d343b"))
 
 	// Upgrade
-	state.SetCode(IshikariPunishContractAddr, code)
+	state.SetCode(addrs[IshikariPunishContractName], code)
 
 	// Change Ishikari Punish Contract state slots
 	//
@@ -63,7 +63,7 @@ func patch001PunishContract(state *state.StateDB) {
 	// │ decreaseRate      │      59      │   0    │ t_uint256
 	//
 
-	state.SetState(IshikariPunishContractAddr,
+	state.SetState(addrs[IshikariPunishContractName],
 		common.BigToHash(big.NewInt(58)),
 		common.BigToHash(big.NewInt(600)))
 }
@@ -88,7 +88,7 @@ func getIshikariPatch002() []patch {
 	}
 }
 
-func patch002PunishContract(state *state.StateDB) {
+func patch002PunishContract(state *state.StateDB, addrs map[string]common.Address) {
 
 	// Change Ishikari Punish Contract state slots
 	//
@@ -99,7 +99,7 @@ func patch002PunishContract(state *state.StateDB) {
 	//
 
 	// For each epoch, a validator can miss at most 2 blocks.
-	state.SetState(IshikariPunishContractAddr,
+	state.SetState(addrs[IshikariPunishContractName],
 		common.BigToHash(big.NewInt(59)),
 		common.BigToHash(big.NewInt(300)))
 }
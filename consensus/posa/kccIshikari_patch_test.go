@@ -18,7 +18,8 @@ func TestVerifyCode(t *testing.T) {
 		t.Fatalf("failed to create statedb: %v", err)
 	}
 
-	patch001PunishContract(stateDB)
-	patch001ValidatorsContract(stateDB)
+	_, addrs := getInteractiveABIAndAddrs()
+	patch001PunishContract(stateDB, addrs)
+	patch001ValidatorsContract(stateDB, addrs)
 
 }
@@ -217,6 +217,10 @@ func New(chainConfig *params.ChainConfig, db ethdb.Database) *POSA {
 
 	interactiveABI, interactiveAddrs := getInteractiveABIAndAddrs()
 
+	// Honour the system contract overrides of the chain config
+	interactiveAddrs[IshikariValidatorsContractName], _ = chainConfig.SystemContract("validators")
+	interactiveAddrs[IshikariPunishContractName], _ = chainConfig.SystemContract("punish")
+
 	return &POSA{
 		chainConfig:   chainConfig,
 		config:        &conf,
@@ -569,7 +573,7 @@ func (c *POSA) Finalize(chain consensus.ChainHeaderReader, header *types.Header,
 	if c.chainConfig.IsIshikariPatch001HardforkBlock(header.Number) {
 		for _, p := range getIshikariPatch001() {
 			// apply each patch
-			p(state)
+			p(state, c.contractAddrs)
 		}
 	}
 
@@ -577,7 +581,7 @@ func (c *POSA) Finalize(chain consensus.ChainHeaderReader, header *types.Header,
 	if c.chainConfig.IsIshikariPatch002HardforkBlock(header.Number) {
 		for _, p := range getIshikariPatch002() {
 			// apply each patch
-			p(state)
+			p(state, c.contractAddrs)
 		}
 	}
 
@@ -641,7 +645,7 @@ func (c *POSA) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header *ty
 	if c.chainConfig.IsIshikariPatch001HardforkBlock(header.Number) {
 		for _, p := range getIshikariPatch001() {
 			// apply each patch
-			p(state)
+			p(state, c.contractAddrs)
 		}
 	}
 
@@ -649,7 +653,7 @@ func (c *POSA) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header *ty
 	if c.chainConfig.IsIshikariPatch002HardforkBlock(header.Number) {
 		for _, p := range getIshikariPatch002() {
 			// apply each patch
-			p(state)
+			p(state, c.contractAddrs)
 		}
 	}
 
@@ -873,7 +877,7 @@ func (c *POSA) updateValidators(chain consensus.ChainHeaderReader, header *types
 
 		// call contract
 		nonce := state.GetNonce(header.Coinbase)
-		addr := IshikariValidatorsContractAddr
+		addr := c.contractAddrs[IshikariValidatorsContractName]
 		msg := types.NewMessage(header.Coinbase, &addr, nonce, new(big.Int), math.MaxUint64, new(big.Int), data, nil, true)
 		if _, err := executeMsg(msg, state, header, newChainContext(chain, c), c.chainConfig); err != nil {
 			log.Error("Can't update validators to contract", "err", err)
@@ -944,7 +948,8 @@ func (c *POSA) decreaseMissedBlocksCounter(chain consensus.ChainHeaderReader, he
 
 		// call contract
 		nonce := state.GetNonce(header.Coinbase)
-		msg := types.NewMessage(header.Coinbase, &IshikariPunishContractAddr, nonce, new(big.Int), math.MaxUint64, new(big.Int), data, nil, true)
+		addr := c.contractAddrs[IshikariPunishContractName]
+		msg := types.NewMessage(header.Coinbase, &addr, nonce, new(big.Int), math.MaxUint64, new(big.Int), data, nil, true)
 		if _, err := executeMsg(msg, state, header, newChainContext(chain, c), c.chainConfig); err != nil {
 			log.Error("Can't decrease missed blocks counter for validator", "err", err)
 			return err
@@ -1147,11 +1152,12 @@ func encodeSigHeader(w io.Writer, header *types.Header) {
 //
 func (c *POSA) initializeSystemContractsIshikari(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB) error {
 
-	if len(c.config.IshikariInitialValidators) == 0 || len(c.config.IshikariInitialManagers) == 0 || len(c.config.IshikariInitialValidators) != len(c.config.IshikariInitialManagers) {
+	managers := c.config.InitialManagers()
+	if len(c.config.IshikariInitialValidators) == 0 || len(managers) == 0 || len(c.config.IshikariInitialValidators) != len(managers) {
 		return errInvalidValidatorsLength
 	}
 
-	for _, contract := range getIshikariSystemContracts(c.abi, c.config.IshikariInitialValidators, c.config.IshikariInitialManagers, c.config.IshikariAdminMultiSig, big.NewInt(int64(c.config.Epoch))) {
+	for _, contract := range getIshikariSystemContracts(c.abi, c.contractAddrs, c.config) {
 
 		state.SetCode(contract.addr, contract.code)
 
@@ -0,0 +1,115 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package posa
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the system contract defaults of the chain config match the
+// addresses the engine interacts with.
+func TestSystemContractDefaults(t *testing.T) {
+	if params.DefaultValidatorContractAddress != IshikariValidatorsContractAddr {
+		t.Errorf("validators contract mismatch: params %x, engine %x", params.DefaultValidatorContractAddress, IshikariValidatorsContractAddr)
+	}
+	if params.DefaultPunishContractAddress != IshikariPunishContractAddr {
+		t.Errorf("punish contract mismatch: params %x, engine %x", params.DefaultPunishContractAddress, IshikariPunishContractAddr)
+	}
+	if params.DefaultProposalContractAddress != IshikariProposalAddr {
+		t.Errorf("proposal contract mismatch: params %x, engine %x", params.DefaultProposalContractAddress, IshikariProposalAddr)
+	}
+	if params.DefaultReservePoolContractAddress != IshikariReservePoolAddr {
+		t.Errorf("reserve pool mismatch: params %x, engine %x", params.DefaultReservePoolContractAddress, IshikariReservePoolAddr)
+	}
+	engine := New(params.MainnetChainConfig, rawdb.NewMemoryDatabase())
+	if addr := engine.contractAddrs[IshikariValidatorsContractName]; addr != IshikariValidatorsContractAddr {
+		t.Errorf("engine validators contract mismatch: have %x, want %x", addr, IshikariValidatorsContractAddr)
+	}
+	if addr := engine.contractAddrs[IshikariPunishContractName]; addr != IshikariPunishContractAddr {
+		t.Errorf("engine punish contract mismatch: have %x, want %x", addr, IshikariPunishContractAddr)
+	}
+}
+
+// Tests that the engine deploys and calls the system contracts at the addresses
+// overridden in the chain config.
+func TestSystemContractOverrides(t *testing.T) {
+	var (
+		config     = params.MainnetChainConfig.Clone()
+		validators = common.HexToAddress("0x000000000000000000000000000000000000a333")
+		punish     = common.HexToAddress("0x000000000000000000000000000000000000a444")
+	)
+	config.POSA.ValidatorContractAddress = &validators
+	config.POSA.PunishContractAddress = &punish
+
+	engine := New(config, rawdb.NewMemoryDatabase())
+	if addr := engine.contractAddrs[IshikariValidatorsContractName]; addr != validators {
+		t.Errorf("validators contract mismatch: have %x, want %x", addr, validators)
+	}
+	if addr := engine.contractAddrs[IshikariPunishContractName]; addr != punish {
+		t.Errorf("punish contract mismatch: have %x, want %x", addr, punish)
+	}
+	contracts := getIshikariSystemContracts(engine.abi, engine.contractAddrs, engine.config)
+	if contracts[0].addr != validators || contracts[2].addr != punish {
+		t.Errorf("deployment addresses mismatch: have %x and %x, want %x and %x", contracts[0].addr, contracts[2].addr, validators, punish)
+	}
+	// The other system contracts must be told about the overrides too
+	want, err := engine.abi[IshikariProposalContractName].Pack("initialize", config.POSA.IshikariAdminMultiSig, validators, punish, IshikariProposalAddr, IshikariReservePoolAddr, big.NewInt(int64(config.POSA.Epoch)))
+	if err != nil {
+		t.Fatalf("failed to pack proposal initializer: %v", err)
+	}
+	if have, err := contracts[1].packFun(); err != nil || string(have) != string(want) {
+		t.Errorf("proposal initializer mismatch: have %x (%v), want %x", have, err, want)
+	}
+}
+
+// Tests that the validator set encoding of the chain config is the initializer
+// calldata of the validators contract.
+func TestGenesisValidatorSetBytes(t *testing.T) {
+	config := params.MainnetChainConfig.POSA
+	feeShares := make([]*big.Int, len(config.IshikariInitialValidators))
+	for i := range feeShares {
+		feeShares[i] = big.NewInt(2000)
+	}
+	abis, _ := getInteractiveABIAndAddrs()
+	want, err := abis[IshikariValidatorsContractName].Pack("initialize",
+		config.IshikariInitialValidators,
+		config.InitialManagers(),
+		feeShares,
+		config.IshikariAdminMultiSig,
+		IshikariValidatorsContractAddr,
+		IshikariPunishContractAddr,
+		IshikariProposalAddr,
+		IshikariReservePoolAddr,
+		new(big.Int).SetUint64(config.Epoch),
+	)
+	if err != nil {
+		t.Fatalf("failed to pack initializer: %v", err)
+	}
+	have, err := config.GenesisValidatorSetBytes()
+	if err != nil {
+		t.Fatalf("failed to encode validator set: %v", err)
+	}
+	if !bytes.Equal(have, want) {
+		t.Errorf("initializer mismatch:\nhave %x\nwant %x", have, want)
+	}
+}
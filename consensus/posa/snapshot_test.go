@@ -0,0 +1,42 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package posa
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the reference proposer seating of the chain config agrees with the
+// in-turn rule of the snapshots.
+func TestProposerAtMatchesInturn(t *testing.T) {
+	config := params.MainnetChainConfig.POSA
+	snap := newSnapshot(config, nil, 0, common.Hash{}, config.IshikariInitialValidators)
+
+	for num := uint64(0); num < 3*uint64(len(config.IshikariInitialValidators)); num++ {
+		proposer, ok := config.ProposerAt(new(big.Int).SetUint64(num))
+		if !ok {
+			t.Fatalf("no proposer at %d", num)
+		}
+		if !snap.inturn(num, proposer) {
+			t.Errorf("block %d: proposer %x not in turn", num, proposer)
+		}
+	}
+}
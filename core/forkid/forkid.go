@@ -24,6 +24,7 @@ import (
 	"math"
 	"math/big"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -111,21 +112,39 @@ func NewStaticFilter(config *params.ChainConfig, genesis common.Hash) Filter {
 	return newFilter(config, genesis, head)
 }
 
+// Cache memoizes the fork checksums of a chain, so that the fork ID can be
+// computed for every peer handshake without regathering and rehashing the forks.
+// It is immutable and safe for concurrent use. Chain configs are never modified
+// once loaded, a new config needs a new cache.
+type Cache struct {
+	forks []uint64  // Fork blocks in ascending order
+	sums  [][4]byte // Checksums after passing the first i forks, 0th is the genesis
+}
+
+// NewCache creates a fork ID cache for the chain config and genesis hash.
+func NewCache(config *params.ChainConfig, genesis common.Hash) *Cache {
+	forks, sums := gatherChecksums(config, genesis)
+	return &Cache{forks: forks, sums: sums}
+}
+
+// ID returns the fork ID at head, equal to NewID with the cached config.
+func (c *Cache) ID(head uint64) ID {
+	passed := sort.Search(len(c.forks), func(i int) bool { return c.forks[i] > head })
+
+	id := ID{Hash: c.sums[passed]}
+	if passed < len(c.forks) {
+		id.Next = c.forks[passed]
+	}
+	return id
+}
+
 // newFilter is the internal version of NewFilter, taking closures as its arguments
 // instead of a chain. The reason is to allow testing it without having to simulate
 // an entire blockchain.
 func newFilter(config *params.ChainConfig, genesis common.Hash, headfn func() uint64) Filter {
 	// Calculate the all the valid fork hash and fork next combos
-	var (
-		forks = gatherForks(config)
-		sums  = make([][4]byte, len(forks)+1) // 0th is the genesis
-	)
-	hash := crc32.ChecksumIEEE(genesis[:])
-	sums[0] = checksumToBytes(hash)
-	for i, fork := range forks {
-		hash = checksumUpdate(hash, fork)
-		sums[i+1] = checksumToBytes(hash)
-	}
+	forks, sums := gatherChecksums(config, genesis)
+
 	// Add two sentries to simplify the fork checks and don't require special
 	// casing the last one.
 	forks = append(forks, math.MaxUint64) // Last fork will never be passed
@@ -196,6 +215,23 @@ func newFilter(config *params.ChainConfig, genesis common.Hash, headfn func() ui
 	}
 }
 
+// gatherChecksums gathers the forks of the config along with the checksums of
+// all the fork states, sums[i] being the checksum after passing the first i
+// forks.
+func gatherChecksums(config *params.ChainConfig, genesis common.Hash) ([]uint64, [][4]byte) {
+	var (
+		forks = gatherForks(config)
+		sums  = make([][4]byte, len(forks)+1) // 0th is the genesis
+	)
+	hash := crc32.ChecksumIEEE(genesis[:])
+	sums[0] = checksumToBytes(hash)
+	for i, fork := range forks {
+		hash = checksumUpdate(hash, fork)
+		sums[i+1] = checksumToBytes(hash)
+	}
+	return forks, sums
+}
+
 // checksumUpdate calculates the next IEEE CRC32 checksum based on the previous
 // one and a fork block number (equivalent to CRC32(original-blob || fork)).
 func checksumUpdate(hash uint32, fork uint64) uint32 {
@@ -224,11 +260,12 @@ func gatherForks(config *params.ChainConfig) []uint64 {
 		if !strings.HasSuffix(field.Name, "Block") {
 			continue
 		}
-		// "CVE_2021_39137Block" is a "fake" hardfork to fix an issue in a past block(#2509228)
-		// It should not be included in the calculation of forkid.
+		// Synthetic forks like "CVE_2021_39137Block" are "fake" hardforks to fix an
+		// issue in a past block(#2509228). They should not be included in the
+		// calculation of forkid.
 		// Fix #9: https://github.com/kcc-community/kcc/issues/9
 		// See more in: core/vm/instructions_kcc_issue_9.go
-		if strings.EqualFold(field.Name, "CVE_2021_39137Block") {
+		if config.IsSyntheticFork(strings.Split(field.Tag.Get("json"), ",")[0]) {
 			continue
 		}
 		if field.Type != reflect.TypeOf(new(big.Int)) {
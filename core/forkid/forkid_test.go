@@ -19,6 +19,7 @@ package forkid
 import (
 	"bytes"
 	"math"
+	"math/big"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -175,3 +176,57 @@ func TestEncoding(t *testing.T) {
 		}
 	}
 }
+
+// ethereumGenesisHash is the genesis hash of the Ethereum mainnet, for which the
+// EIP-2124 specification publishes fork ID test vectors.
+var ethereumGenesisHash = common.HexToHash("0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3")
+
+// ethereumEarlyForks is a config with the early Ethereum mainnet forks.
+var ethereumEarlyForks = &params.ChainConfig{
+	HomesteadBlock:      big.NewInt(1150000),
+	DAOForkBlock:        big.NewInt(1920000),
+	EIP150Block:         big.NewInt(2463000),
+	EIP155Block:         big.NewInt(2675000),
+	EIP158Block:         big.NewInt(2675000),
+	CVE_2021_39137Block: big.NewInt(2000000), // synthetic, must not affect the fork ID
+}
+
+// Tests that the cached fork IDs match the EIP-2124 test vectors and NewID.
+func TestCache(t *testing.T) {
+	cache := NewCache(ethereumEarlyForks, ethereumGenesisHash)
+
+	tests := []struct {
+		head uint64
+		want ID
+	}{
+		{0, ID{Hash: checksumToBytes(0xfc64ec04), Next: 1150000}},
+		{1149999, ID{Hash: checksumToBytes(0xfc64ec04), Next: 1150000}},
+		{1150000, ID{Hash: checksumToBytes(0x97c2c34c), Next: 1920000}},
+		{1920000, ID{Hash: checksumToBytes(0x91d1f948), Next: 2463000}},
+		{2463000, ID{Hash: checksumToBytes(0x7a64da13), Next: 2675000}},
+		{2675000, ID{Hash: checksumToBytes(0x3edd5b10), Next: 0}},
+		{math.MaxUint64, ID{Hash: checksumToBytes(0x3edd5b10), Next: 0}},
+	}
+	for i, tt := range tests {
+		if have := cache.ID(tt.head); have != tt.want {
+			t.Errorf("test %d: fork ID mismatch at %d: have %x, want %x", i, tt.head, have, tt.want)
+		}
+		if have, want := cache.ID(tt.head), NewID(ethereumEarlyForks, ethereumGenesisHash, tt.head); have != want {
+			t.Errorf("test %d: cached fork ID differs from NewID at %d: have %x, want %x", i, tt.head, have, want)
+		}
+	}
+}
+
+func BenchmarkID(b *testing.B) {
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			NewID(ethereumEarlyForks, ethereumGenesisHash, uint64(i))
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		cache := NewCache(ethereumEarlyForks, ethereumGenesisHash)
+		for i := 0; i < b.N; i++ {
+			cache.ID(uint64(i))
+		}
+	})
+}
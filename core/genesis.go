@@ -214,6 +214,9 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 		rawdb.WriteChainConfig(db, stored, newcfg)
 		return newcfg, stored, nil
 	}
+	if storedcfg.ConfigVersion > params.ConfigSchemaVersion {
+		log.Warn("Stored chain config from a newer schema, unknown fields ignored", "version", storedcfg.ConfigVersion, "supported", params.ConfigSchemaVersion)
+	}
 	// Special case: don't change the existing config of a non-mainnet chain if no new
 	// config is supplied. These chains would get AllProtocolChanges (and a compat error)
 	// if we just continued here.
@@ -278,6 +281,9 @@ func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
 	}
 	if g.GasLimit == 0 {
 		head.GasLimit = params.GenesisGasLimit
+		if g.Config != nil {
+			head.GasLimit = g.Config.InitialGasLim()
+		}
 	}
 	if g.Difficulty == nil {
 		head.Difficulty = params.GenesisDifficulty
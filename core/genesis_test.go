@@ -136,6 +136,7 @@ func TestSetupGenesis(t *testing.T) {
 				StoredConfig: big.NewInt(2),
 				NewConfig:    big.NewInt(3),
 				RewindTo:     1,
+				Direction:    params.CompatStoredAhead,
 			},
 		},
 	}
@@ -21,10 +21,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"testing"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 // precompiledTest defines the input/output pairs for precompiled contract tests.
@@ -391,3 +393,24 @@ func BenchmarkPrecompiledBLS12381G2MultiExpWorstCase(b *testing.B) {
 	}
 	benchmarkPrecompiled("0f", testcase, b)
 }
+
+// Tests that the precompile sets resolved by the chain rules match the EVM's.
+func TestRulesPrecompileSet(t *testing.T) {
+	config := &params.ChainConfig{ByzantiumBlock: big.NewInt(1), IstanbulBlock: big.NewInt(2), BerlinBlock: big.NewInt(3)}
+	for num, precompiles := range []map[common.Address]PrecompiledContract{
+		PrecompiledContractsHomestead,
+		PrecompiledContractsByzantium,
+		PrecompiledContractsIstanbul,
+		PrecompiledContractsBerlin,
+	} {
+		set := config.Rules(big.NewInt(int64(num))).PrecompileSet()
+		if len(set) != len(precompiles) {
+			t.Errorf("block %d: precompile count mismatch: have %d, want %d", num, len(set), len(precompiles))
+		}
+		for addr := range precompiles {
+			if !set[addr] {
+				t.Errorf("block %d: precompile %x missing", num, addr)
+			}
+		}
+	}
+}
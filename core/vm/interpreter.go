@@ -128,6 +128,14 @@ func NewEVMInterpreter(evm *EVM, cfg Config) *EVMInterpreter {
 				log.Error("EIP activation failed", "eip", eip, "error", err)
 			}
 		}
+		// Opcodes disabled by the chain config behave as if undefined
+		if len(evm.chainConfig.DisabledOpcodes) > 0 {
+			for name := range evm.chainConfig.DisabledOpcodesAt(evm.Context.BlockNumber) {
+				if op, ok := stringToOp[name]; ok {
+					jt[op] = nil
+				}
+			}
+		}
 
 		cfg.JumpTable = jt
 	}
@@ -0,0 +1,75 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the opcode names accepted by the chain config match the opcodes
+// known to the EVM.
+func TestConfigOpcodeNames(t *testing.T) {
+	for name := range stringToOp {
+		if !params.IsOpcodeName(name) {
+			t.Errorf("opcode %s unknown to params", name)
+		}
+	}
+	if params.IsOpcodeName("SELFDESTRUCTX") {
+		t.Errorf("bogus opcode accepted")
+	}
+}
+
+// Tests that opcodes disabled by the chain config are rejected as invalid once
+// the disabling fork is active, and only then.
+func TestDisabledOpcodes(t *testing.T) {
+	config := params.TestChainConfig.Clone()
+	config.IshikariBlock = big.NewInt(10)
+	config.DisabledOpcodes = map[string][]string{"ishikari": {"SELFDESTRUCT"}}
+
+	for _, tt := range []struct {
+		number   int64
+		disabled bool
+	}{
+		{9, false},
+		{10, true},
+		{11, true},
+	} {
+		env := NewEVM(BlockContext{BlockNumber: big.NewInt(tt.number)}, TxContext{}, nil, config, Config{})
+		interpreter := env.interpreter.(*EVMInterpreter)
+		if disabled := interpreter.cfg.JumpTable[SELFDESTRUCT] == nil; disabled != tt.disabled {
+			t.Errorf("block %d: SELFDESTRUCT disabled mismatch: have %v, want %v", tt.number, disabled, tt.disabled)
+		}
+		if interpreter.cfg.JumpTable[CALL] == nil {
+			t.Errorf("block %d: CALL disabled", tt.number)
+		}
+		if !tt.disabled {
+			continue
+		}
+		contract := NewContract(AccountRef(common.Address{}), AccountRef(common.Address{1}), new(big.Int), 100000)
+		contract.Code = []byte{byte(SELFDESTRUCT)}
+
+		var invalid *ErrInvalidOpCode
+		if _, err := interpreter.Run(contract, nil, false); !errors.As(err, &invalid) {
+			t.Errorf("block %d: disabled opcode not rejected: %v", tt.number, err)
+		}
+	}
+}
@@ -58,6 +58,10 @@ import (
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
+// imminentForkWindow is the number of blocks ahead of the head within which an
+// upcoming fork is reported at startup (about a day with 3 second blocks).
+const imminentForkWindow = 28800
+
 // Config contains the configuration options of the ETH protocol.
 // Deprecated: use ethconfig.Config instead.
 type Config = ethconfig.Config
@@ -199,6 +203,13 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
 
+	// Warn loudly about forks activating soon, a client not supporting them would
+	// fall off the network at the fork block.
+	head := eth.blockchain.CurrentHeader().Number.Uint64()
+	for _, fork := range chainConfig.ImminentForks(head, imminentForkWindow) {
+		log.Warn("Hard fork activates soon, ensure your client is up to date", "fork", fork, "head", head)
+	}
+
 	// set state fn if consensus engine is posa.
 	if posaEngine, ok := eth.engine.(*posa.POSA); ok {
 		posaEngine.SetStateFn(eth.blockchain.StateAt)
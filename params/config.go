@@ -17,13 +17,23 @@
 package params
 
 import (
+	"bytes"
 	"encoding/binary"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"math/big"
-
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
 )
 
 // Genesis hashes to enforce below configs on.
@@ -36,14 +46,111 @@ var (
 // the chain it belongs to.
 var TrustedCheckpoints = map[common.Hash]*TrustedCheckpoint{}
 
+// trustedCheckpointsLock guards TrustedCheckpoints against concurrent
+// registrations.
+var trustedCheckpointsLock sync.RWMutex
+
+// RegisterCheckpoint registers cp as the trusted checkpoint of the chain with the
+// given genesis hash. Empty checkpoints and checkpoints older than the one
+// already registered are rejected.
+func RegisterCheckpoint(genesis common.Hash, cp *TrustedCheckpoint) error {
+	if cp == nil || cp.Empty() {
+		return fmt.Errorf("empty checkpoint")
+	}
+	trustedCheckpointsLock.Lock()
+	defer trustedCheckpointsLock.Unlock()
+
+	if old := TrustedCheckpoints[genesis]; old != nil && cp.SectionIndex < old.SectionIndex {
+		return fmt.Errorf("stale checkpoint: section %d, have %d", cp.SectionIndex, old.SectionIndex)
+	}
+	TrustedCheckpoints[genesis] = cp
+	return nil
+}
+
+// LatestCheckpoint returns the trusted checkpoint registered for the chain with
+// the given genesis hash.
+func LatestCheckpoint(genesis common.Hash) (*TrustedCheckpoint, bool) {
+	trustedCheckpointsLock.RLock()
+	defer trustedCheckpointsLock.RUnlock()
+
+	cp, ok := TrustedCheckpoints[genesis]
+	return cp, ok
+}
+
+// TrustedCheckpointEntry is a trusted checkpoint along with the genesis hash of
+// the chain it belongs to.
+type TrustedCheckpointEntry struct {
+	Genesis    common.Hash
+	Checkpoint *TrustedCheckpoint
+}
+
+// AllTrustedCheckpoints returns all the registered trusted checkpoints, sorted
+// by genesis hash.
+func AllTrustedCheckpoints() []TrustedCheckpointEntry {
+	trustedCheckpointsLock.RLock()
+	defer trustedCheckpointsLock.RUnlock()
+
+	entries := make([]TrustedCheckpointEntry, 0, len(TrustedCheckpoints))
+	for genesis, cp := range TrustedCheckpoints {
+		entries = append(entries, TrustedCheckpointEntry{Genesis: genesis, Checkpoint: cp})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].Genesis[:], entries[j].Genesis[:]) < 0
+	})
+	return entries
+}
+
 // CheckpointOracles associates each known checkpoint oracles with the genesis hash of
 // the chain it belongs to.
 var CheckpointOracles = map[common.Hash]*CheckpointOracleConfig{}
 
+// Built-in checkpoint oracles of the mainnet and the testnet. No oracle contract
+// is deployed on either network yet, so they are placeholders without contract
+// address and signers to be filled in, e.g. by light client tests. They are kept
+// out of CheckpointOracles until they are complete, light clients would reject
+// them anyway.
+var (
+	MainnetCheckpointOracle = &CheckpointOracleConfig{}
+	TestnetCheckpointOracle = &CheckpointOracleConfig{}
+)
+
+// DefaultCheckpointOracle returns the built-in checkpoint oracle of the chain
+// with the given genesis hash, if it's a known network.
+func DefaultCheckpointOracle(genesis common.Hash) (*CheckpointOracleConfig, bool) {
+	switch genesis {
+	case MainnetGenesisHash:
+		return MainnetCheckpointOracle, true
+	case TestnetGenesisHash:
+		return TestnetCheckpointOracle, true
+	}
+	return nil, false
+}
+
+// CheckpointOracleEntry is a checkpoint oracle along with the genesis hash of
+// the chain it belongs to.
+type CheckpointOracleEntry struct {
+	Genesis common.Hash
+	Oracle  *CheckpointOracleConfig
+}
+
+// AllCheckpointOracles returns all the known checkpoint oracles, sorted by
+// genesis hash.
+func AllCheckpointOracles() []CheckpointOracleEntry {
+	entries := make([]CheckpointOracleEntry, 0, len(CheckpointOracles))
+	for genesis, oracle := range CheckpointOracles {
+		entries = append(entries, CheckpointOracleEntry{Genesis: genesis, Oracle: oracle})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].Genesis[:], entries[j].Genesis[:]) < 0
+	})
+	return entries
+}
+
 var (
 	// MainnetChainConfig is the chain parameters to run a node on the main network.
 	MainnetChainConfig = &ChainConfig{
 		ChainID:             big.NewInt(126),
+		ConfigVersion:       ConfigSchemaVersion,
 		HomesteadBlock:      big.NewInt(0),
 		DAOForkBlock:        nil,
 		DAOForkSupport:      true,
@@ -63,8 +170,8 @@ var (
 		IshikariPatch001Block: big.NewInt(11171299),
 		IshikariPatch002Block: big.NewInt(11171299),
 		POSA: &POSAConfig{
-			Period:                    3,
-			Epoch:                     100,
+			Period: 3,
+			Epoch:  100,
 			IshikariInitialValidators: []common.Address{
 				common.HexToAddress("0x1105c97ffbd985600e6dc8e06e477b99d0a9ff39"),
 				common.HexToAddress("0xeac6d9b96c73a637ba9d7a54dc4faece0300fcb3"),
@@ -82,7 +189,7 @@ var (
 				common.HexToAddress("0xad291383864e1999fc7a36120562f1bb59dfea99"),
 			}, // @cary @Junm TODO: Ishikari initial validators
 
-			IshikariInitialManagers:   []common.Address{
+			IshikariInitialManagers: []common.Address{
 				common.HexToAddress("0x65E958D3EA7e60F33098dc665B0C8B7Dc563FA72"),
 				common.HexToAddress("0x6586e16EB5574f79bA4Cfa46C3b37bAEAAC50f32"),
 				common.HexToAddress("0xCCbb95B446e7CFd23fb80374b92d1F6F33e073E2"),
@@ -98,13 +205,14 @@ var (
 				common.HexToAddress("0xb9D71eF2D3A31588EF9196e66d69EE20B7302af8"),
 				common.HexToAddress("0x68A6a68d03D405af7E4676e5D92AD4BD7d1d004a"),
 			},
-			IshikariAdminMultiSig:     common.HexToAddress("0xD4139cc315164d4dcC696a18902F2e6b7B5D3de8"),
+			IshikariAdminMultiSig: common.HexToAddress("0xD4139cc315164d4dcC696a18902F2e6b7B5D3de8"),
 		},
 	}
 
 	// TestnetChainConfig contains the chain parameters to run a node on the Ropsten test network.
 	TestnetChainConfig = &ChainConfig{
 		ChainID:             big.NewInt(322),
+		ConfigVersion:       ConfigSchemaVersion,
 		HomesteadBlock:      big.NewInt(0),
 		DAOForkBlock:        nil,
 		DAOForkSupport:      true,
@@ -154,18 +262,25 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, new(EthashConfig), nil, nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, ConfigSchemaVersion, new(EthashConfig), nil, nil, nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Ethereum core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, ConfigSchemaVersion, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil, nil}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, new(EthashConfig), nil, nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, ConfigSchemaVersion, new(EthashConfig), nil, nil, nil}
 )
 
+func init() {
+	// Catch typos in the built-in networks as early as possible, rather than on
+	// node startup.
+	MainnetChainConfig.MustValidate()
+	TestnetChainConfig.MustValidate()
+}
+
 // TrustedCheckpoint represents a set of post-processed trie roots (CHT and
 // BloomTrie) associated with the appropriate section index and head hash. It is
 // used to start light syncing from this checkpoint and avoid downloading the
@@ -200,6 +315,15 @@ func (c *TrustedCheckpoint) Empty() bool {
 	return c.SectionHead == (common.Hash{}) || c.CHTRoot == (common.Hash{}) || c.BloomRoot == (common.Hash{})
 }
 
+// String implements the fmt.Stringer interface, abbreviating the hashes.
+func (c *TrustedCheckpoint) String() string {
+	if c == nil {
+		return "checkpoint(nil)"
+	}
+	return fmt.Sprintf("checkpoint(section=%d head=0x%s cht=0x%s bloom=0x%s empty=%v)",
+		c.SectionIndex, c.SectionHead.TerminalString(), c.CHTRoot.TerminalString(), c.BloomRoot.TerminalString(), c.Empty())
+}
+
 // CheckpointOracleConfig represents a set of checkpoint contract(which acts as an oracle)
 // config which used for light client checkpoint syncing.
 type CheckpointOracleConfig struct {
@@ -238,6 +362,10 @@ type ChainConfig struct {
 	// This is a "fake" hardfork to fix an issue in a past block(#2509228).
 	// By saying it is "fake", we mean it should behave as if it does not exist.
 	// The hardfork should not reflect on the forkid.
+	// The block itself is the last one processed with the vulnerable code, the
+	// fix applies from the next block on (see IsCVE202139137).
+	// Unlike the real forks, nil means the fix always applies and 0 that it
+	// applies from block 1 on; changing either would change consensus.
 	// see more in : core/vm/instructions_kcc_issue_9.go
 	CVE_2021_39137Block *big.Int `json:"cve_2021_39137Block,omitempty"`
 
@@ -253,15 +381,49 @@ type ChainConfig struct {
 	// The punishment parameters for mainnet is determined in this hardfork
 	IshikariPatch002Block *big.Int `json:"ishikariPatch002Block,omitempty"`
 
+	// London fee market semantics for interoperability with Ethereum tooling
+	// (nil = follow Berlin, see IsLondon). Ordered after the Ishikari forks.
+	LondonBlock *big.Int `json:"londonBlock,omitempty"`
+
+	// Scaffolding of a merge-style transition to proof-of-stake, inert while
+	// TerminalTotalDifficulty is unset, see IsPostMerge
+	TerminalTotalDifficulty *big.Int `json:"terminalTotalDifficulty,omitempty"` // Total difficulty of the last pre-merge block
+	MergeNetsplitBlock      *big.Int `json:"mergeNetsplitBlock,omitempty"`      // Virtual fork after the merge to split the network
+
 	YoloV3Block *big.Int `json:"yoloV3Block,omitempty"` // YOLO v3: Gas repricings TODO @holiman add EIP references
 	EWASMBlock  *big.Int `json:"ewasmBlock,omitempty"`  // EWASM switch block (nil = no fork, 0 = already activated)
 
+	// EIP-1559 fee market parameters (nil = use the protocol defaults)
+	BaseFeeChangeDenominator *uint64 `json:"baseFeeChangeDenominator,omitempty"` // Bounds the amount the base fee can change between blocks
+	ElasticityMultiplier     *uint64 `json:"elasticityMultiplier,omitempty"`     // Bounds the maximum gas limit an EIP-1559 block may have
+
+	// Block size policy (nil = use the defaults)
+	InitialGasLimit *uint64 `json:"initialGasLimit,omitempty"` // Gas limit of the genesis block
+	GasTarget       *uint64 `json:"gasTarget,omitempty"`       // Gas used per block the network aims for
+
+	// Minimum client versions (semver, e.g. "v1.2.0") required once a fork is
+	// active, keyed by fork name
+	MinClientVersions map[string]string `json:"minClientVersions,omitempty"`
+
+	// EVM opcodes (by name, e.g. "SELFDESTRUCT") disabled once a fork is active,
+	// keyed by fork name. The interpreter treats them as undefined opcodes.
+	DisabledOpcodes map[string][]string `json:"disabledOpcodes,omitempty"`
+
+	// System addresses exempt from fee accounting, see IsFeeExempt
+	FeeExemptAddresses []common.Address `json:"feeExemptAddresses,omitempty"`
+
+	// Schema version of the serialized config, see ConfigSchemaVersion. Zero on
+	// configs decoded from a source predating schema versioning.
+	ConfigVersion uint64 `json:"configVersion,omitempty"`
+
 	//
 
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
 	POSA   *POSAConfig   `json:"posa,omitempty"`
+
+	feeExempt map[common.Address]struct{} // Lookup set of FeeExemptAddresses, built on decoding
 }
 
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
@@ -288,183 +450,2290 @@ type POSAConfig struct {
 	Period uint64 `json:"period"` // Number of seconds between blocks to enforce
 	Epoch  uint64 `json:"epoch"`  // Epoch length to reset votes and checkpoint
 
+	// CheckpointInterval overrides the Epoch as the number of blocks between
+	// snapshot checkpoints, leaving vote resets at epoch boundaries (0 = Epoch).
+	CheckpointInterval uint64 `json:"checkpointInterval,omitempty"`
+
 	// Ishikari hardfork related configs
 	// Ishikari initial validators
 	IshikariInitialValidators []common.Address `json:"ishikariInitialValidators"`
 	IshikariInitialManagers   []common.Address `json:"ishikariInitialManagers"`
+	// Whether the initial managers may be omitted, making every initial validator
+	// its own manager, see InitialManagers
+	ManagersOptional bool `json:"managersOptional,omitempty"`
 	// Ishikari admin multisig Address
 	IshikariAdminMultiSig common.Address `json:"ishikariAdminAddress"`
+
+	// System contract overrides (nil = the built-in defaults), see SystemContract
+	ValidatorContractAddress *common.Address `json:"validatorContractAddress,omitempty"`
+	PunishContractAddress    *common.Address `json:"punishContractAddress,omitempty"`
+
+	// Punishment parameters, consulted by the punish contract
+	JailThreshold uint64 `json:"jailThreshold,omitempty"` // Missed blocks before a validator is jailed (0 = contract default)
+
+	// Capacity planning of the validator set, consulted by the validator contract
+	MaxValidators         uint64              `json:"maxValidators,omitempty"`         // Validator cap without a schedule entry (0 = unlimited)
+	MaxValidatorsSchedule []MaxValidatorsStep `json:"maxValidatorsSchedule,omitempty"` // Validator caps taking effect at given blocks
+}
+
+// Built-in addresses of the Ishikari system contracts, mirroring the ones in
+// consensus/posa.
+var (
+	DefaultValidatorContractAddress   = common.HexToAddress("0x000000000000000000000000000000000000f333")
+	DefaultPunishContractAddress      = common.HexToAddress("0x000000000000000000000000000000000000f444")
+	DefaultProposalContractAddress    = common.HexToAddress("0x000000000000000000000000000000000000f555")
+	DefaultReservePoolContractAddress = common.HexToAddress("0x000000000000000000000000000000000000f999")
+)
+
+// SystemContract returns the address of the named POSA system contract, either
+// "validators" or "punish", falling back to the built-in default unless the POSA
+// config overrides it. It returns false for unknown names and non-POSA chains.
+func (c *ChainConfig) SystemContract(name string) (common.Address, bool) {
+	if c.POSA == nil {
+		return common.Address{}, false
+	}
+	validators, punish := c.POSA.systemContracts()
+	switch name {
+	case "validators":
+		return validators, true
+	case "punish":
+		return punish, true
+	default:
+		return common.Address{}, false
+	}
+}
+
+// systemContracts returns the addresses of the validators and punish contracts,
+// falling back to the built-in defaults unless overridden.
+func (c *POSAConfig) systemContracts() (validators, punish common.Address) {
+	validators, punish = DefaultValidatorContractAddress, DefaultPunishContractAddress
+	if c.ValidatorContractAddress != nil {
+		validators = *c.ValidatorContractAddress
+	}
+	if c.PunishContractAddress != nil {
+		punish = *c.PunishContractAddress
+	}
+	return validators, punish
+}
+
+// IsCheckpoint returns whether num is a checkpoint block, i.e. a multiple of the
+// checkpoint interval, or of the epoch length if no separate interval is set.
+func (c *POSAConfig) IsCheckpoint(num *big.Int) bool {
+	interval := c.CheckpointInterval
+	if interval == 0 {
+		interval = c.Epoch
+	}
+	if interval == 0 || num == nil {
+		return false
+	}
+	return new(big.Int).Mod(num, new(big.Int).SetUint64(interval)).Sign() == 0
+}
+
+// MaxValidatorsStep is an entry of the validator cap schedule: from Block on,
+// the validator set may contain at most Max validators.
+type MaxValidatorsStep struct {
+	Block *big.Int `json:"block"`
+	Max   uint64   `json:"max"`
+}
+
+// MaxValidatorsAt returns the validator cap in effect at block num, falling back
+// to MaxValidators before the first scheduled step. Absent any cap it returns
+// math.MaxUint64, i.e. unlimited.
+func (c *POSAConfig) MaxValidatorsAt(num *big.Int) uint64 {
+	limit := c.MaxValidators
+	for _, step := range c.MaxValidatorsSchedule {
+		if !isForked(step.Block, num) {
+			break
+		}
+		limit = step.Max
+	}
+	if limit == 0 {
+		return math.MaxUint64
+	}
+	return limit
 }
 
+var (
+	// ErrPOSAPeriod is returned if the POSA block period is invalid.
+	ErrPOSAPeriod = errors.New("invalid POSA period")
+
+	// ErrPOSAEpoch is returned if the POSA epoch length is invalid, or doesn't
+	// line up with the Ishikari fork.
+	ErrPOSAEpoch = errors.New("invalid POSA epoch")
+
+	// ErrPOSANoValidators is returned if Ishikari is scheduled without an initial
+	// validator set.
+	ErrPOSANoValidators = errors.New("missing POSA initial validators")
+
+	// ErrValidatorManagerMismatch is returned if the initial validators and their
+	// managers can't be paired up positionally.
+	ErrValidatorManagerMismatch = errors.New("POSA validator manager mismatch")
+)
+
+// Bounds of the POSA block period: ValidateWithReport warns above the soft limit,
+// POSAConfig.Validate rejects periods above the hard one. They are variables so
+// that exotic chains can lift them.
+var (
+	POSAPeriodSoftLimit uint64 = 60   // seconds
+	POSAPeriodHardLimit uint64 = 3600 // seconds
+)
+
+// POSAMaxEpochDuration is the epoch duration above which ValidateWithReport
+// warns about a likely mistyped POSA epoch length.
+var POSAMaxEpochDuration = 24 * time.Hour
+
+// POSAMinEpoch is the minimum epoch length accepted by POSAConfig.Validate. It's
+// a variable so that private networks and tests can raise or lower it.
+var POSAMinEpoch uint64 = 2
+
 // Validate POSA Contraints
 func (c *POSAConfig) Validate(chainCfg *ChainConfig) error {
+	return c.validate(chainCfg.IshikariBlock, true)
+}
 
+// validate checks the POSA constraints against the Ishikari fork block, skipping
+// the initial validator set if seed is false, e.g. for configs trimmed for light
+// clients.
+func (c *POSAConfig) validate(ishikari *big.Int, seed bool) error {
 	if c.Period == 0 {
-		return fmt.Errorf("POSAConfig.Period should not be 0")
+		return fmt.Errorf("%w: POSAConfig.Period should not be 0", ErrPOSAPeriod)
+	}
+	if c.Period > POSAPeriodHardLimit {
+		return fmt.Errorf("%w: POSAConfig.Period %d exceeds the limit of %d seconds", ErrPOSAPeriod, c.Period, POSAPeriodHardLimit)
+	}
+
+	if c.Epoch < POSAMinEpoch {
+		return fmt.Errorf("%w: POSAConfig.Epoch should be not be less than %d", ErrPOSAEpoch, POSAMinEpoch)
+	}
+
+	// The epoch duration in seconds must be computable without overflowing
+	if c.Epoch > math.MaxUint64/c.Period {
+		return fmt.Errorf("%w: POSAConfig.Epoch %d times POSAConfig.Period %d overflows", ErrPOSAEpoch, c.Epoch, c.Period)
+	}
+
+	// A separate checkpoint interval must line up with the epochs, so that every
+	// epoch boundary is a checkpoint or every checkpoint is an epoch boundary
+	if c.CheckpointInterval != 0 && c.Epoch%c.CheckpointInterval != 0 && c.CheckpointInterval%c.Epoch != 0 {
+		return fmt.Errorf("%w: POSAConfig.CheckpointInterval %d neither divides nor is a multiple of the epoch %d", ErrPOSAEpoch, c.CheckpointInterval, c.Epoch)
 	}
 
-	if c.Epoch < 2 {
-		return fmt.Errorf("POSAConfig.Epoch should be not be less than 2")
+	for i, step := range c.MaxValidatorsSchedule {
+		if step.Block == nil {
+			return fmt.Errorf("POSAConfig.MaxValidatorsSchedule[%d] has no block", i)
+		}
+		if i > 0 && step.Block.Cmp(c.MaxValidatorsSchedule[i-1].Block) <= 0 {
+			return fmt.Errorf("POSAConfig.MaxValidatorsSchedule not strictly increasing: entry %d at block %v, entry %d at block %v",
+				i-1, c.MaxValidatorsSchedule[i-1].Block, i, step.Block)
+		}
 	}
 
-	if chainCfg.IshikariBlock == nil {
+	if ishikari == nil {
 		// if Ishikari hardfork is not enabled yet,
 		// we don't need to verify other fields at this moment.
 		return nil
 	}
 
-	if len(c.IshikariInitialManagers) < 1 {
-		return fmt.Errorf("length of POSAConfig.V2InitialManagers must not be less than 1")
+	if seed {
+		if err := c.validateIshikariSeed(); err != nil {
+			return err
+		}
 	}
 
-	if len(c.IshikariInitialManagers) != len(c.IshikariInitialValidators) {
-		return fmt.Errorf("numbers of initial validators & initial managers do not match (%v!=%v)",
-			len(c.IshikariInitialManagers), len(c.IshikariInitialValidators))
+	// The hardfork should happen at the last block of some epoch
+	if (ishikari.Uint64()+1)%c.Epoch != 0 {
+		return fmt.Errorf("%w: IshikariBlock should be the last block of some epoch", ErrPOSAEpoch)
 	}
 
-	// The hardfork should happen at the last block of some epoch
-	if chainCfg.IshikariBlock != nil && ((chainCfg.IshikariBlock.Uint64()+1)%c.Epoch != 0) {
-		return fmt.Errorf("IshikariBlock should be the last block of some epoch")
+	return nil
+}
+
+// validateIshikariSeed checks the initial validator set the Ishikari contracts
+// are seeded with.
+func (c *POSAConfig) validateIshikariSeed() error {
+	managers := c.InitialManagers()
+	if len(managers) < 1 {
+		return fmt.Errorf("%w: length of POSAConfig.V2InitialManagers must not be less than 1", ErrPOSANoValidators)
+	}
+
+	if len(managers) != len(c.IshikariInitialValidators) {
+		return fmt.Errorf("%w: numbers of initial validators & initial managers do not match (%v!=%v)", ErrValidatorManagerMismatch,
+			len(managers), len(c.IshikariInitialValidators))
 	}
 
+	// Every initial validator must be able to seal once within an epoch, with
+	// one more block to spare for the checkpoint rotating the set
+	if validators := uint64(len(c.IshikariInitialValidators)); c.Epoch < validators+1 {
+		return fmt.Errorf("%w: POSAConfig.Epoch too short for the initial validators: epoch (%d) must be >= validators (%d) + 1", ErrPOSAEpoch,
+			c.Epoch, validators)
+	}
 	return nil
 }
 
-// String implements the stringer interface, returning the consensus engine details.
-func (c *POSAConfig) String() string {
-	d, _ := json.Marshal(c)
-	return fmt.Sprintf("posa(%v)", string(d))
+// ishikariInitialFeeShare is the fee share every initial validator starts with.
+const ishikariInitialFeeShare = 2000
+
+// ishikariValidatorsInitialize is the initializer of the Ishikari validators
+// contract, called by the POSA engine on the Ishikari block.
+var ishikariValidatorsInitialize = abi.NewMethod("initialize", "initialize", abi.Function, "", false, false, abi.Arguments{
+	{Name: "_validators", Type: mustNewABIType("address[]")},
+	{Name: "_managers", Type: mustNewABIType("address[]")},
+	{Name: "_feeShares", Type: mustNewABIType("uint256[]")},
+	{Name: "_admin", Type: mustNewABIType("address")},
+	{Name: "_validatorsContract", Type: mustNewABIType("address")},
+	{Name: "_punishContract", Type: mustNewABIType("address")},
+	{Name: "_proposalContract", Type: mustNewABIType("address")},
+	{Name: "_reservePool", Type: mustNewABIType("address")},
+	{Name: "_epoch", Type: mustNewABIType("uint256")},
+}, nil)
+
+// mustNewABIType creates the ABI type t, panicking on failure.
+func mustNewABIType(t string) abi.Type {
+	typ, err := abi.NewType(t, "", nil)
+	if err != nil {
+		panic(err)
+	}
+	return typ
 }
 
-// String implements the fmt.Stringer interface.
-func (c *ChainConfig) String() string {
-	var engine interface{}
-	switch {
-	case c.Ethash != nil:
-		engine = c.Ethash
-	case c.Clique != nil:
-		engine = c.Clique
-	case c.POSA != nil:
-		engine = c.POSA
-	default:
-		engine = "unknown"
+// GenesisValidatorSetBytes returns the calldata the Ishikari validators contract
+// is initialized with: the initial validators, their managers and fee shares, the
+// admin multisig, the system contract addresses and the epoch. The POSA engine
+// seeds the contract with it on the Ishikari block. It fails if the initial
+// validator set is invalid.
+func (c *POSAConfig) GenesisValidatorSetBytes() ([]byte, error) {
+	if err := c.validateIshikariSeed(); err != nil {
+		return nil, err
 	}
-	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Petersburg: %v Istanbul: %v, Muir Glacier: %v, Berlin: %v, cve_2021_39137Block:%v, Ishikari: %v, IshikariPatch: %v, YOLO v3: %v, Engine: %v}",
-		c.ChainID,
-		c.HomesteadBlock,
-		c.DAOForkBlock,
-		c.DAOForkSupport,
-		c.EIP150Block,
-		c.EIP155Block,
-		c.EIP158Block,
-		c.ByzantiumBlock,
-		c.ConstantinopleBlock,
-		c.PetersburgBlock,
-		c.IstanbulBlock,
-		c.MuirGlacierBlock,
-		c.BerlinBlock,
-		c.CVE_2021_39137Block,
-		c.IshikariBlock,
-		c.IshikariPatch001Block,
-		c.YoloV3Block,
-		engine,
+	feeShares := make([]*big.Int, len(c.IshikariInitialValidators))
+	for i := range feeShares {
+		feeShares[i] = big.NewInt(ishikariInitialFeeShare)
+	}
+	validators, punish := c.systemContracts()
+	args, err := ishikariValidatorsInitialize.Inputs.Pack(
+		c.IshikariInitialValidators,
+		c.InitialManagers(),
+		feeShares,
+		c.IshikariAdminMultiSig,
+		validators,
+		punish,
+		DefaultProposalContractAddress,
+		DefaultReservePoolContractAddress,
+		new(big.Int).SetUint64(c.Epoch),
 	)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, ishikariValidatorsInitialize.ID...), args...), nil
 }
 
-// IsHomestead returns whether num is either equal to the homestead block or greater.
-func (c *ChainConfig) IsHomestead(num *big.Int) bool {
-	return isForked(c.HomesteadBlock, num)
+// ValidateTransition checks whether the config may replace the old one at head,
+// e.g. when a governance proposal changes the POSA parameters. Punishment and
+// capacity parameters may change at any time, but once the Ishikari fork at the
+// given block (nil if unscheduled) is active, the block timing and the validator
+// set seeding the system contracts are fixed.
+func (c *POSAConfig) ValidateTransition(old *POSAConfig, head, ishikari *big.Int) error {
+	if err := c.validate(ishikari, true); err != nil {
+		return err
+	}
+	if !isForked(ishikari, head) {
+		return nil
+	}
+	if c.Period != old.Period {
+		return fmt.Errorf("%w: POSAConfig.Period can't change after Ishikari (%d != %d)", ErrPOSAPeriod, c.Period, old.Period)
+	}
+	if c.Epoch != old.Epoch {
+		return fmt.Errorf("%w: POSAConfig.Epoch can't change after Ishikari (%d != %d)", ErrPOSAEpoch, c.Epoch, old.Epoch)
+	}
+	if !addressesEqual(c.IshikariInitialValidators, old.IshikariInitialValidators) {
+		return fmt.Errorf("POSAConfig.IshikariInitialValidators can't change after Ishikari")
+	}
+	if !addressesEqual(c.IshikariInitialManagers, old.IshikariInitialManagers) {
+		return fmt.Errorf("POSAConfig.IshikariInitialManagers can't change after Ishikari")
+	}
+	if c.IshikariAdminMultiSig != old.IshikariAdminMultiSig {
+		return fmt.Errorf("POSAConfig.IshikariAdminMultiSig can't change after Ishikari")
+	}
+	return nil
 }
 
-// IsDAOFork returns whether num is either equal to the DAO fork block or greater.
-func (c *ChainConfig) IsDAOFork(num *big.Int) bool {
-	return isForked(c.DAOForkBlock, num)
+// Equal reports whether the two POSA configs are identical. The validator and
+// manager lists are compared order sensitively since the order determines the
+// seating of the validators, but nil and empty lists are considered equal.
+func (c *POSAConfig) Equal(other *POSAConfig) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	if c.Period != other.Period || c.Epoch != other.Epoch || c.IshikariAdminMultiSig != other.IshikariAdminMultiSig {
+		return false
+	}
+	if !addressesEqual(c.IshikariInitialValidators, other.IshikariInitialValidators) {
+		return false
+	}
+	if !addressesEqual(c.IshikariInitialManagers, other.IshikariInitialManagers) {
+		return false
+	}
+	if !addressPtrEqual(c.ValidatorContractAddress, other.ValidatorContractAddress) || !addressPtrEqual(c.PunishContractAddress, other.PunishContractAddress) {
+		return false
+	}
+	if c.ManagersOptional != other.ManagersOptional || c.CheckpointInterval != other.CheckpointInterval || c.JailThreshold != other.JailThreshold || c.MaxValidators != other.MaxValidators {
+		return false
+	}
+	if len(c.MaxValidatorsSchedule) != len(other.MaxValidatorsSchedule) {
+		return false
+	}
+	for i, step := range c.MaxValidatorsSchedule {
+		if !configNumEqual(step.Block, other.MaxValidatorsSchedule[i].Block) || step.Max != other.MaxValidatorsSchedule[i].Max {
+			return false
+		}
+	}
+	return true
 }
 
-// IsEIP150 returns whether num is either equal to the EIP150 fork block or greater.
-func (c *ChainConfig) IsEIP150(num *big.Int) bool {
-	return isForked(c.EIP150Block, num)
+// addressPtrEqual reports whether two optional addresses are equal.
+func addressPtrEqual(a, b *common.Address) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
 
-// IsEIP155 returns whether num is either equal to the EIP155 fork block or greater.
-func (c *ChainConfig) IsEIP155(num *big.Int) bool {
-	return isForked(c.EIP155Block, num)
+// addressesEqual reports whether the two address lists are identical.
+func addressesEqual(a, b []common.Address) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
 }
 
-// IsEIP158 returns whether num is either equal to the EIP158 fork block or greater.
-func (c *ChainConfig) IsEIP158(num *big.Int) bool {
-	return isForked(c.EIP158Block, num)
+// ProposerAt returns the in-turn proposer of block num according to the initial
+// validator set, mirroring the seating of the consensus engine: the validators
+// are sorted by address in ascending byte order, and block num is in turn for
+//
+//	sorted[num % len(sorted)]
+//
+// Once the validator set changes on chain, the live set must be used instead.
+// It returns false if there are no initial validators.
+func (c *POSAConfig) ProposerAt(num *big.Int) (common.Address, bool) {
+	if len(c.IshikariInitialValidators) == 0 || num == nil {
+		return common.Address{}, false
+	}
+	sorted := append([]common.Address(nil), c.IshikariInitialValidators...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i][:], sorted[j][:]) < 0
+	})
+	index := new(big.Int).Mod(num, big.NewInt(int64(len(sorted))))
+	return sorted[index.Uint64()], true
 }
 
-// IsByzantium returns whether num is either equal to the Byzantium fork block or greater.
-func (c *ChainConfig) IsByzantium(num *big.Int) bool {
-	return isForked(c.ByzantiumBlock, num)
+// ValidateAgainstGenesisAlloc checks that the validators seeded into the
+// validator contract by the genesis alloc are the initial validators of the
+// config. The lists are compared as sets, their order doesn't matter.
+func (c *POSAConfig) ValidateAgainstGenesisAlloc(allocValidators []common.Address) error {
+	var (
+		configured = make(map[common.Address]bool, len(c.IshikariInitialValidators))
+		allocated  = make(map[common.Address]bool, len(allocValidators))
+	)
+	for _, addr := range c.IshikariInitialValidators {
+		configured[addr] = true
+	}
+	for _, addr := range allocValidators {
+		allocated[addr] = true
+		if !configured[addr] {
+			return fmt.Errorf("genesis alloc validator %s missing from POSAConfig.IshikariInitialValidators", addr.Hex())
+		}
+	}
+	for _, addr := range c.IshikariInitialValidators {
+		if !allocated[addr] {
+			return fmt.Errorf("POSAConfig.IshikariInitialValidators validator %s missing from genesis alloc", addr.Hex())
+		}
+	}
+	return nil
 }
 
-// IsConstantinople returns whether num is either equal to the Constantinople fork block or greater.
-func (c *ChainConfig) IsConstantinople(num *big.Int) bool {
-	return isForked(c.ConstantinopleBlock, num)
+// IndexOfValidator returns the position of the validator in the initial
+// validator set as configured, or -1 if it's not an initial validator. Note that
+// the proposer rotation seats the validators by address instead, see ProposerAt.
+func (c *POSAConfig) IndexOfValidator(addr common.Address) (int, bool) {
+	for i, v := range c.IshikariInitialValidators {
+		if v == addr {
+			return i, true
+		}
+	}
+	return -1, false
 }
 
-// IsMuirGlacier returns whether num is either equal to the Muir Glacier (EIP-2384) fork block or greater.
-func (c *ChainConfig) IsMuirGlacier(num *big.Int) bool {
-	return isForked(c.MuirGlacierBlock, num)
+// InitialManagers returns the managers of the initial validators, positionally
+// paired. If the managers are optional and omitted, every validator is its own
+// manager.
+func (c *POSAConfig) InitialManagers() []common.Address {
+	if c.ManagersOptional && len(c.IshikariInitialManagers) == 0 {
+		return c.IshikariInitialValidators
+	}
+	return c.IshikariInitialManagers
 }
 
-// IsPetersburg returns whether num is either
-// - equal to or greater than the PetersburgBlock fork block,
-// - OR is nil, and Constantinople is active
-func (c *ChainConfig) IsPetersburg(num *big.Int) bool {
-	return isForked(c.PetersburgBlock, num) || c.PetersburgBlock == nil && isForked(c.ConstantinopleBlock, num)
+// ManagerForValidator returns the manager paired with the given initial
+// validator. The pairing is positional, so it is only defined when the
+// validator and manager lists have the same length.
+func (c *POSAConfig) ManagerForValidator(validator common.Address) (common.Address, bool) {
+	managers := c.InitialManagers()
+	if len(c.IshikariInitialValidators) != len(managers) {
+		return common.Address{}, false
+	}
+	for i, v := range c.IshikariInitialValidators {
+		if v == validator {
+			return managers[i], true
+		}
+	}
+	return common.Address{}, false
 }
 
-// IsIstanbul returns whether num is either equal to the Istanbul fork block or greater.
-func (c *ChainConfig) IsIstanbul(num *big.Int) bool {
-	return isForked(c.IstanbulBlock, num)
+// Clone returns a deep copy of the POSA config.
+func (c *POSAConfig) Clone() *POSAConfig {
+	cpy := *c
+	cpy.IshikariInitialValidators = append([]common.Address(nil), c.IshikariInitialValidators...)
+	cpy.IshikariInitialManagers = append([]common.Address(nil), c.IshikariInitialManagers...)
+	if c.ValidatorContractAddress != nil {
+		addr := *c.ValidatorContractAddress
+		cpy.ValidatorContractAddress = &addr
+	}
+	if c.PunishContractAddress != nil {
+		addr := *c.PunishContractAddress
+		cpy.PunishContractAddress = &addr
+	}
+	if c.MaxValidatorsSchedule != nil {
+		cpy.MaxValidatorsSchedule = make([]MaxValidatorsStep, len(c.MaxValidatorsSchedule))
+		for i, step := range c.MaxValidatorsSchedule {
+			cpy.MaxValidatorsSchedule[i] = MaxValidatorsStep{Block: cloneBig(step.Block), Max: step.Max}
+		}
+	}
+	return &cpy
 }
 
-// IsBerlin returns whether num is either equal to the Berlin fork block or greater.
-func (c *ChainConfig) IsBerlin(num *big.Int) bool {
-	return isForked(c.BerlinBlock, num) || isForked(c.YoloV3Block, num)
+// AddValidatorPair returns a copy of the config with the validator and its
+// manager appended to the initial sets. The receiver is left untouched.
+func (c *POSAConfig) AddValidatorPair(validator, manager common.Address) (*POSAConfig, error) {
+	if validator == (common.Address{}) || manager == (common.Address{}) {
+		return nil, fmt.Errorf("validator and manager must not be the zero address")
+	}
+	for _, v := range c.IshikariInitialValidators {
+		if v == validator {
+			return nil, fmt.Errorf("validator %v already present", validator.Hex())
+		}
+	}
+	cpy := c.Clone()
+	cpy.IshikariInitialValidators = append(cpy.IshikariInitialValidators, validator)
+	cpy.IshikariInitialManagers = append(cpy.IshikariInitialManagers, manager)
+	return cpy, nil
 }
 
-// IsEWASM returns whether num represents a block number after the EWASM fork
-func (c *ChainConfig) IsEWASM(num *big.Int) bool {
-	return isForked(c.EWASMBlock, num)
+// RemoveValidator returns a copy of the config with the validator and the manager
+// at the same position removed from the initial sets. The receiver is left untouched.
+func (c *POSAConfig) RemoveValidator(validator common.Address) (*POSAConfig, error) {
+	for i, v := range c.IshikariInitialValidators {
+		if v != validator {
+			continue
+		}
+		cpy := c.Clone()
+		cpy.IshikariInitialValidators = append(cpy.IshikariInitialValidators[:i], cpy.IshikariInitialValidators[i+1:]...)
+		if i < len(cpy.IshikariInitialManagers) {
+			cpy.IshikariInitialManagers = append(cpy.IshikariInitialManagers[:i], cpy.IshikariInitialManagers[i+1:]...)
+		}
+		return cpy, nil
+	}
+	return nil, fmt.Errorf("validator %v not present", validator.Hex())
 }
 
-// is Ishikari hardfork enabled ?
-func (c *ChainConfig) IsKCCIshikari(num *big.Int) bool {
-	return isForked(c.IshikariBlock, num)
+// String implements the stringer interface, returning the consensus engine details.
+// The validator and manager sets are only summarized, use StringVerbose to list them.
+func (c *POSAConfig) String() string {
+	return fmt.Sprintf("posa(period=%d epoch=%d validators=%d managers=%d admin=%s)",
+		c.Period, c.Epoch, len(c.IshikariInitialValidators), len(c.IshikariInitialManagers), c.IshikariAdminMultiSig.Hex())
 }
 
-// is the block number "num" when Ishikari hardfork happens ?
-func (c *ChainConfig) IsIshikariHardforkBlock(num *big.Int) bool {
-	if num == nil || c.IshikariBlock == nil {
-		return false
+// StringVerbose returns the consensus engine details, including the full initial
+// validator and manager sets.
+func (c *POSAConfig) StringVerbose() string {
+	return fmt.Sprintf("posa(period=%d epoch=%d validators=%s managers=%s admin=%s)",
+		c.Period, c.Epoch, addressList(c.IshikariInitialValidators), addressList(c.IshikariInitialManagers), c.IshikariAdminMultiSig.Hex())
+}
+
+// addressList formats a list of addresses in their checksummed form.
+func addressList(addrs []common.Address) string {
+	items := make([]string, len(addrs))
+	for i, addr := range addrs {
+		items[i] = addr.Hex()
 	}
-	return num.Cmp(c.IshikariBlock) == 0
+	return "[" + strings.Join(items, " ") + "]"
 }
 
-// is the block number "num" when IshikariPatch001 hardfork happens ?
-func (c *ChainConfig) IsIshikariPatch001HardforkBlock(num *big.Int) bool {
-	if num == nil || c.IshikariPatch001Block == nil {
-		return false
+// ParseAddressList parses a list of hex addresses separated by commas, spaces or
+// newlines, e.g. pasted validator sets. Blank entries are skipped. Invalid
+// entries are reported with their line and their index in the list.
+func ParseAddressList(s string) ([]common.Address, error) {
+	var addrs []common.Address
+	for i, line := range strings.Split(s, "\n") {
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		})
+		for _, field := range fields {
+			if !common.IsHexAddress(field) {
+				return nil, fmt.Errorf("line %d: invalid address %q at index %d", i+1, field, len(addrs))
+			}
+			addrs = append(addrs, common.HexToAddress(field))
+		}
 	}
-	return num.Cmp(c.IshikariPatch001Block) == 0
+	return addrs, nil
 }
 
-// is the block number "num" when IshikariPatch002 hardfork happens ?
-func (c *ChainConfig) IsIshikariPatch002HardforkBlock(num *big.Int) bool {
-	if num == nil || c.IshikariPatch002Block == nil {
-		return false
+// BaseFeeChangeDenom returns the EIP-1559 base fee change denominator, falling
+// back to the protocol default if the chain doesn't configure one.
+func (c *ChainConfig) BaseFeeChangeDenom() uint64 {
+	if c.BaseFeeChangeDenominator != nil {
+		return *c.BaseFeeChangeDenominator
 	}
-	return num.Cmp(c.IshikariPatch002Block) == 0
+	return BaseFeeChangeDenominator
 }
 
-// CheckCompatible checks whether scheduled fork transitions have been imported
-// with a mismatching chain configuration.
-func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *ConfigCompatError {
+// ElasticityMult returns the EIP-1559 elasticity multiplier, falling back to
+// the protocol default if the chain doesn't configure one.
+func (c *ChainConfig) ElasticityMult() uint64 {
+	if c.ElasticityMultiplier != nil {
+		return *c.ElasticityMultiplier
+	}
+	return ElasticityMultiplier
+}
+
+// InitialGasLim returns the gas limit of the genesis block, falling back to the
+// protocol default if the chain doesn't configure one.
+func (c *ChainConfig) InitialGasLim() uint64 {
+	if c.InitialGasLimit != nil {
+		return *c.InitialGasLimit
+	}
+	return GenesisGasLimit
+}
+
+// TargetGas returns the gas used per block the network aims for, falling back
+// to the EIP-1559 target implied by the initial gas limit and the elasticity
+// multiplier if the chain doesn't configure one.
+func (c *ChainConfig) TargetGas() uint64 {
+	if c.GasTarget != nil {
+		return *c.GasTarget
+	}
+	return c.InitialGasLim() / c.ElasticityMult()
+}
+
+// ApplyDefaults materializes the fork blocks which are implied by other forks,
+// making the config fully explicit without changing any activation semantics:
+//
+//   - a nil PetersburgBlock is set to ConstantinopleBlock
+//   - BerlinBlock is set to YoloV3Block if that activates Berlin earlier
+func (c *ChainConfig) ApplyDefaults() {
+	if c.PetersburgBlock == nil && c.ConstantinopleBlock != nil {
+		c.PetersburgBlock = new(big.Int).Set(c.ConstantinopleBlock)
+	}
+	if c.YoloV3Block != nil && (c.BerlinBlock == nil || c.YoloV3Block.Cmp(c.BerlinBlock) < 0) {
+		c.BerlinBlock = new(big.Int).Set(c.YoloV3Block)
+	}
+}
+
+// ValidationReport is the detailed outcome of validating a chain config, listing
+// every check that ran and everything they found, e.g. for startup logging.
+type ValidationReport struct {
+	Errors   []string // Problems rendering the config unusable
+	Warnings []string // Suspicious settings which are nonetheless accepted
+	Checks   []string // Names of the checks that ran, in execution order
+
+	errs []error // Original errors behind Errors
+}
+
+// check records that the named check ran, and its failure if any.
+func (r *ValidationReport) check(name string, err error) {
+	r.Checks = append(r.Checks, name)
+	if err != nil {
+		r.Errors = append(r.Errors, err.Error())
+		r.errs = append(r.errs, err)
+	}
+}
+
+// Err returns the first error found during validation, or nil if there was none.
+func (r *ValidationReport) Err() error {
+	if len(r.errs) == 0 {
+		return nil
+	}
+	return r.errs[0]
+}
+
+// StrictErr is like Err, but also treats the warnings as errors.
+func (r *ValidationReport) StrictErr() error {
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if len(r.Warnings) > 0 {
+		return errors.New(r.Warnings[0])
+	}
+	return nil
+}
+
+// Validate checks the chain config for inconsistent or invalid parameters. It
+// returns the first error reported by ValidateWithReport.
+func (c *ChainConfig) Validate() error {
+	return c.ValidateWithReport().Err()
+}
+
+// MustValidate is like Validate, but panics if the config is invalid. It's meant
+// for asserting the validity of hard coded configs.
+func (c *ChainConfig) MustValidate() {
+	if err := c.Validate(); err != nil {
+		panic(fmt.Sprintf("invalid chain config (chain ID %v): %v", c.ChainID, err))
+	}
+}
+
+// ValidateStrict is like Validate, but also rejects the config on any warning.
+func (c *ChainConfig) ValidateStrict() error {
+	return c.ValidateWithReport().StrictErr()
+}
+
+// ValidateWithReport runs all the validity checks on the chain config, collecting
+// their findings instead of stopping at the first error.
+func (c *ChainConfig) ValidateWithReport() *ValidationReport {
+	return c.validateWithReport(true)
+}
+
+// ValidateForLightClient is like Validate, but accepts configs without the POSA
+// initial validator set, as produced by ExportForLightClient.
+func (c *ChainConfig) ValidateForLightClient() error {
+	return c.validateWithReport(false).Err()
+}
+
+// validateWithReport runs the validity checks, skipping the POSA initial
+// validator set unless seed is set.
+func (c *ChainConfig) validateWithReport(seed bool) *ValidationReport {
+	report := new(ValidationReport)
+
+	report.check("chain-id", c.validateChainID(report))
+	report.check("config-version", c.validateConfigVersion(report))
+	report.check("fork-order", c.CheckConfigForkOrder())
+	report.check("eip150-hash", c.validateEIP150Hash(report))
+	report.check("fee-params", c.validateFeeParams())
+	report.check("merge", c.validateMerge())
+	report.check("gas-limits", c.validateGasLimits())
+	report.check("client-versions", c.validateClientVersions())
+	report.check("disabled-opcodes", c.validateDisabledOpcodes())
+	report.check("fee-exempt", c.validateFeeExempt())
+	if c.POSA != nil {
+		report.check("posa", c.POSA.validate(c.IshikariBlock, seed))
+		report.check("posa-period", c.validatePeriod(report))
+		report.check("posa-epoch-duration", c.validateEpochDuration(report))
+	}
+	return report
+}
+
+// validateConfigVersion warns if the config was serialized by a newer version
+// of the schema, in which case any unknown fields were silently dropped.
+func (c *ChainConfig) validateConfigVersion(report *ValidationReport) error {
+	if c.ConfigVersion > ConfigSchemaVersion {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("configVersion %d is newer than the supported %d, unknown fields are ignored",
+			c.ConfigVersion, ConfigSchemaVersion))
+	}
+	return nil
+}
+
+// validateEIP150Hash warns if EIP150 activates after genesis without the hash
+// of its fork block, which header-only clients need to verify the fork.
+func (c *ChainConfig) validateEIP150Hash(report *ValidationReport) error {
+	if c.EIP150Block != nil && c.EIP150Block.Sign() > 0 && c.EIP150Hash == (common.Hash{}) {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("eip150Hash not set for eip150Block %v, header-only clients can't verify the fork", c.EIP150Block))
+	}
+	return nil
+}
+
+// validateChainID checks the chain ID, warning if it's missing since that
+// disables replay protection.
+func (c *ChainConfig) validateChainID(report *ValidationReport) error {
+	if c.ChainID == nil {
+		report.Warnings = append(report.Warnings, "chainId not set, replay protection unavailable")
+		return nil
+	}
+	if c.ChainID.Sign() <= 0 {
+		return fmt.Errorf("chainId should be positive, have %v", c.ChainID)
+	}
+	return nil
+}
+
+// validatePeriod warns if the POSA block period is suspiciously long. Periods
+// beyond the hard limit are rejected by POSAConfig.Validate already.
+func (c *ChainConfig) validatePeriod(report *ValidationReport) error {
+	if c.POSA.Period > POSAPeriodSoftLimit && c.POSA.Period <= POSAPeriodHardLimit {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("posa period of %d seconds exceeds %d seconds", c.POSA.Period, POSAPeriodSoftLimit))
+	}
+	return nil
+}
+
+// validateEpochDuration warns if a POSA epoch lasts suspiciously long. Overflows
+// are rejected by POSAConfig.Validate already.
+func (c *ChainConfig) validateEpochDuration(report *ValidationReport) error {
+	if c.POSA.Period == 0 || c.POSA.Epoch > math.MaxUint64/c.POSA.Period {
+		return nil
+	}
+	seconds := c.POSA.Epoch * c.POSA.Period
+	if seconds > uint64(POSAMaxEpochDuration/time.Second) {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("posa epoch lasts %v, more than %v", time.Duration(seconds)*time.Second, POSAMaxEpochDuration))
+	}
+	return nil
+}
+
+// validateMerge checks the merge transition parameters.
+func (c *ChainConfig) validateMerge() error {
+	if c.TerminalTotalDifficulty != nil && c.TerminalTotalDifficulty.Sign() < 0 {
+		return fmt.Errorf("terminalTotalDifficulty (%v) should not be negative", c.TerminalTotalDifficulty)
+	}
+	return nil
+}
+
+// validateFeeParams checks the base fee parameters overrides.
+func (c *ChainConfig) validateFeeParams() error {
+	if c.BaseFeeChangeDenominator != nil && *c.BaseFeeChangeDenominator == 0 {
+		return fmt.Errorf("baseFeeChangeDenominator should not be 0")
+	}
+	if c.ElasticityMultiplier != nil && *c.ElasticityMultiplier == 0 {
+		return fmt.Errorf("elasticityMultiplier should not be 0")
+	}
+	return nil
+}
+
+// validateGasLimits checks the block size policy overrides.
+func (c *ChainConfig) validateGasLimits() error {
+	if c.InitialGasLimit != nil && *c.InitialGasLimit < MinGasLimit {
+		return fmt.Errorf("initialGasLimit should not be less than %d, have %d", MinGasLimit, *c.InitialGasLimit)
+	}
+	if c.GasTarget != nil && *c.GasTarget == 0 {
+		return fmt.Errorf("gasTarget should not be 0")
+	}
+	if c.InitialGasLimit != nil && c.GasTarget != nil && *c.GasTarget > *c.InitialGasLimit {
+		return fmt.Errorf("gasTarget (%d) should not exceed initialGasLimit (%d)", *c.GasTarget, *c.InitialGasLimit)
+	}
+	return nil
+}
+
+// validateClientVersions checks that the minimum client versions refer to known
+// forks and are well formed.
+func (c *ChainConfig) validateClientVersions() error {
+	for name, version := range c.MinClientVersions {
+		if _, ok := c.fork(name); !ok {
+			return fmt.Errorf("minClientVersions: unknown fork %q", name)
+		}
+		if _, ok := parseSemver(version); !ok {
+			return fmt.Errorf("minClientVersions: malformed version %q for fork %q", version, name)
+		}
+	}
+	return nil
+}
+
+// validateDisabledOpcodes checks that the disabled opcodes refer to known forks
+// and opcodes.
+func (c *ChainConfig) validateDisabledOpcodes() error {
+	for name, ops := range c.DisabledOpcodes {
+		if _, ok := c.fork(name); !ok {
+			return fmt.Errorf("disabledOpcodes: unknown fork %q", name)
+		}
+		for _, op := range ops {
+			if !IsOpcodeName(op) {
+				return fmt.Errorf("disabledOpcodes: unknown opcode %q for fork %q", op, name)
+			}
+		}
+	}
+	return nil
+}
+
+// validateFeeExempt checks that no fee exempt address is the zero address, which
+// usually stems from a malformed entry.
+func (c *ChainConfig) validateFeeExempt() error {
+	for i, addr := range c.FeeExemptAddresses {
+		if addr == (common.Address{}) {
+			return fmt.Errorf("feeExemptAddresses[%d] is the zero address", i)
+		}
+	}
+	return nil
+}
+
+// IsFeeExempt returns whether addr is one of the FeeExemptAddresses. Configs
+// decoded from JSON answer from a lookup set built on decoding, so their list
+// must not be modified afterwards; other configs, e.g. built in code or cloned,
+// scan the list.
+func (c *ChainConfig) IsFeeExempt(addr common.Address) bool {
+	if c == nil {
+		return false
+	}
+	if c.feeExempt != nil {
+		_, ok := c.feeExempt[addr]
+		return ok
+	}
+	for _, exempt := range c.FeeExemptAddresses {
+		if exempt == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// DisabledOpcodesAt returns the set of opcodes disabled by the forks active at
+// num, which the EVM interpreter rejects as invalid.
+func (c *ChainConfig) DisabledOpcodesAt(num *big.Int) map[string]bool {
+	disabled := make(map[string]bool)
+	for name, ops := range c.DisabledOpcodes {
+		fork, ok := c.fork(name)
+		if !ok || !c.isForkActive(fork, num) {
+			continue
+		}
+		for _, op := range ops {
+			disabled[op] = true
+		}
+	}
+	return disabled
+}
+
+// RequiredClientVersion returns the highest minimum client version required by
+// the forks active at num, if any of them has a requirement.
+func (c *ChainConfig) RequiredClientVersion(num *big.Int) (string, bool) {
+	var (
+		required string
+		highest  [3]uint64
+	)
+	for name, version := range c.MinClientVersions {
+		fork, ok := c.fork(name)
+		if !ok || !isForked(*fork.block, num) {
+			continue
+		}
+		parsed, ok := parseSemver(version)
+		if !ok {
+			continue
+		}
+		if required == "" || compareSemver(parsed, highest) > 0 {
+			required, highest = version, parsed
+		}
+	}
+	return required, required != ""
+}
+
+// parseSemver parses a "vMAJOR.MINOR.PATCH" version string, the "v" prefix
+// being optional.
+func parseSemver(version string) ([3]uint64, bool) {
+	var parsed [3]uint64
+
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) != 3 {
+		return parsed, false
+	}
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil || (len(part) > 1 && part[0] == '0') {
+			return parsed, false
+		}
+		parsed[i] = n
+	}
+	return parsed, true
+}
+
+// compareSemver returns -1, 0 or 1 depending on whether a is lower, equal or
+// higher than b.
+func compareSemver(a, b [3]uint64) int {
+	for i := range a {
+		switch {
+		case a[i] < b[i]:
+			return -1
+		case a[i] > b[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// BlockPeriod returns the number of seconds between blocks enforced by the
+// consensus engine, or false if the engine (e.g. ethash) doesn't have one.
+func (c *ChainConfig) BlockPeriod() (uint64, bool) {
+	switch {
+	case c.Clique != nil:
+		return c.Clique.Period, true
+	case c.POSA != nil:
+		return c.POSA.Period, true
+	}
+	return 0, false
+}
+
+// EpochLength returns the epoch length of the consensus engine, or false if
+// the engine (e.g. ethash) doesn't have one.
+func (c *ChainConfig) EpochLength() (uint64, bool) {
+	switch {
+	case c.Clique != nil:
+		return c.Clique.Epoch, true
+	case c.POSA != nil:
+		return c.POSA.Epoch, true
+	}
+	return 0, false
+}
+
+// Extra-data layout of the POSA and Clique headers, the vanity prefix and the
+// seal suffix (a 65 byte secp256k1 signature) every header carries.
+const (
+	extraDataVanity = 32
+	extraDataSeal   = 65
+)
+
+// ExtraDataMinLength returns the minimum number of header extra-data bytes at
+// block num, zero for engines without a sealed extra-data layout (ethash). POSA
+// and Clique headers carry the vanity and the seal; before the Ishikari hardfork,
+// POSA headers additionally carry the validator set, i.e. at least one address.
+func (c *ChainConfig) ExtraDataMinLength(num *big.Int) int {
+	switch {
+	case c.POSA != nil && !c.IsKCCIshikari(num):
+		return extraDataVanity + common.AddressLength + extraDataSeal
+	case c.POSA != nil || c.Clique != nil:
+		return extraDataVanity + extraDataSeal
+	default:
+		return 0
+	}
+}
+
+// ValidateBlockTime checks the timestamp of block num against its parent's. All
+// engines require strictly increasing timestamps, POSA chains additionally need
+// at least the block period between consecutive blocks. The period is not
+// scheduled per fork, so num only features in the error messages.
+func (c *ChainConfig) ValidateBlockTime(parentTime, blockTime uint64, num *big.Int) error {
+	if blockTime <= parentTime {
+		return fmt.Errorf("block %v timestamp %d not after parent timestamp %d", num, blockTime, parentTime)
+	}
+	if c.POSA != nil {
+		if min := parentTime + c.POSA.Period; min < parentTime || blockTime < min {
+			return fmt.Errorf("block %v timestamp %d too early: parent timestamp %d, period %d", num, blockTime, parentTime, c.POSA.Period)
+		}
+	}
+	return nil
+}
+
+// Clone returns a deep copy of the chain config.
+func (c *ChainConfig) Clone() *ChainConfig {
+	cpy := *c
+
+	cpy.ChainID = cloneBig(c.ChainID)
+	cpy.HomesteadBlock = cloneBig(c.HomesteadBlock)
+	cpy.DAOForkBlock = cloneBig(c.DAOForkBlock)
+	cpy.EIP150Block = cloneBig(c.EIP150Block)
+	cpy.EIP155Block = cloneBig(c.EIP155Block)
+	cpy.EIP158Block = cloneBig(c.EIP158Block)
+	cpy.ByzantiumBlock = cloneBig(c.ByzantiumBlock)
+	cpy.ConstantinopleBlock = cloneBig(c.ConstantinopleBlock)
+	cpy.PetersburgBlock = cloneBig(c.PetersburgBlock)
+	cpy.IstanbulBlock = cloneBig(c.IstanbulBlock)
+	cpy.MuirGlacierBlock = cloneBig(c.MuirGlacierBlock)
+	cpy.BerlinBlock = cloneBig(c.BerlinBlock)
+	cpy.CVE_2021_39137Block = cloneBig(c.CVE_2021_39137Block)
+	cpy.IshikariBlock = cloneBig(c.IshikariBlock)
+	cpy.IshikariPatch001Block = cloneBig(c.IshikariPatch001Block)
+	cpy.IshikariPatch002Block = cloneBig(c.IshikariPatch002Block)
+	cpy.LondonBlock = cloneBig(c.LondonBlock)
+	cpy.TerminalTotalDifficulty = cloneBig(c.TerminalTotalDifficulty)
+	cpy.MergeNetsplitBlock = cloneBig(c.MergeNetsplitBlock)
+	cpy.YoloV3Block = cloneBig(c.YoloV3Block)
+	cpy.EWASMBlock = cloneBig(c.EWASMBlock)
+
+	cpy.BaseFeeChangeDenominator = cloneUint64(c.BaseFeeChangeDenominator)
+	cpy.ElasticityMultiplier = cloneUint64(c.ElasticityMultiplier)
+	cpy.InitialGasLimit = cloneUint64(c.InitialGasLimit)
+	cpy.GasTarget = cloneUint64(c.GasTarget)
+
+	if c.MinClientVersions != nil {
+		cpy.MinClientVersions = make(map[string]string, len(c.MinClientVersions))
+		for fork, version := range c.MinClientVersions {
+			cpy.MinClientVersions[fork] = version
+		}
+	}
+	if c.DisabledOpcodes != nil {
+		cpy.DisabledOpcodes = make(map[string][]string, len(c.DisabledOpcodes))
+		for fork, ops := range c.DisabledOpcodes {
+			cpy.DisabledOpcodes[fork] = append([]string(nil), ops...)
+		}
+	}
+	if c.FeeExemptAddresses != nil {
+		cpy.FeeExemptAddresses = append([]common.Address{}, c.FeeExemptAddresses...)
+	}
+	cpy.feeExempt = nil // the copied list may be modified, drop the lookup set
+
+	if c.Ethash != nil {
+		cpy.Ethash = new(EthashConfig)
+	}
+	if c.Clique != nil {
+		clique := *c.Clique
+		cpy.Clique = &clique
+	}
+	if c.POSA != nil {
+		cpy.POSA = c.POSA.Clone()
+	}
+	return &cpy
+}
+
+// WithChainID returns a deep copy of the chain config with the chain ID replaced,
+// e.g. to run a test network on the mainnet rules without replay collisions. It
+// panics if id is nil or not positive, as that's a programming error.
+func (c *ChainConfig) WithChainID(id *big.Int) *ChainConfig {
+	if id == nil || id.Sign() <= 0 {
+		panic(fmt.Sprintf("invalid chain ID %v", id))
+	}
+	cpy := c.Clone()
+	cpy.ChainID = new(big.Int).Set(id)
+	return cpy
+}
+
+// WithFork returns a copy of the config with the named fork scheduled at block
+// (nil unsets it), for fluent fixture construction in tests, e.g. mainnet with
+// Ishikari moved to block 5. The name may be given in its canonical form or as
+// its JSON key. It panics on unknown fork names, as that's a programming error.
+func (c *ChainConfig) WithFork(name string, block *big.Int) *ChainConfig {
+	cpy := c.Clone()
+	fork, ok := cpy.fork(name)
+	if !ok {
+		panic(fmt.Sprintf("unknown fork %q", name))
+	}
+	*fork.block = cloneBig(block)
+	return cpy
+}
+
+// ExportForLightClient returns a deep copy of the chain config trimmed for light
+// clients, which only need the fork schedule and the checkpoints: the POSA
+// initial validators and managers are dropped, the other POSA parameters kept.
+// The result passes ValidateForLightClient, but not necessarily Validate.
+func (c *ChainConfig) ExportForLightClient() *ChainConfig {
+	cpy := c.Clone()
+	if cpy.POSA != nil {
+		cpy.POSA.IshikariInitialValidators = []common.Address{}
+		cpy.POSA.IshikariInitialManagers = []common.Address{}
+	}
+	return cpy
+}
+
+// Merge returns a deep copy of the chain config with the given consensus engine
+// config (*EthashConfig, *CliqueConfig or *POSAConfig) set, for deployments
+// keeping the fork schedule and the engine config apart. It fails if the config
+// already has an engine, if the engine type is unknown, or if the merged config
+// doesn't validate.
+func (c *ChainConfig) Merge(engine interface{}) (*ChainConfig, error) {
+	if c.Ethash != nil || c.Clique != nil || c.POSA != nil {
+		return nil, fmt.Errorf("chain config already has a consensus engine: %v", c.engine())
+	}
+	cpy := c.Clone()
+	if err := cpy.SetEngine(engine); err != nil {
+		return nil, err
+	}
+	if err := cpy.Validate(); err != nil {
+		return nil, err
+	}
+	return cpy, nil
+}
+
+// SetEngine replaces the consensus engine of the config with a copy of the given
+// engine config (*EthashConfig, *CliqueConfig or *POSAConfig), clearing all the
+// others. The config is left untouched if the engine type is unknown or nil.
+func (c *ChainConfig) SetEngine(engine interface{}) error {
+	cpy := *c
+	cpy.Ethash, cpy.Clique, cpy.POSA = nil, nil, nil
+
+	switch engine := engine.(type) {
+	case *EthashConfig:
+		if engine != nil {
+			cpy.Ethash = new(EthashConfig)
+		}
+	case *CliqueConfig:
+		if engine != nil {
+			clique := *engine
+			cpy.Clique = &clique
+		}
+	case *POSAConfig:
+		if engine != nil {
+			cpy.POSA = engine.Clone()
+		}
+	default:
+		return fmt.Errorf("unknown consensus engine config type %T", engine)
+	}
+	if err := cpy.RequiresConsensusEngine(); err != nil {
+		return err
+	}
+	*c = cpy
+	return nil
+}
+
+// RequiresConsensusEngine returns an error unless exactly one consensus engine
+// is configured. String and the engine constructors silently pick the first one
+// set, so multiple engines are most likely a mistake.
+func (c *ChainConfig) RequiresConsensusEngine() error {
+	var engines []string
+	if c.Ethash != nil {
+		engines = append(engines, "ethash")
+	}
+	if c.Clique != nil {
+		engines = append(engines, "clique")
+	}
+	if c.POSA != nil {
+		engines = append(engines, "posa")
+	}
+	switch len(engines) {
+	case 0:
+		return errors.New("no consensus engine configured")
+	case 1:
+		return nil
+	default:
+		return fmt.Errorf("multiple consensus engines configured: %s", strings.Join(engines, ", "))
+	}
+}
+
+// engine returns the configured consensus engine config, for display.
+func (c *ChainConfig) engine() interface{} {
+	switch {
+	case c.Ethash != nil:
+		return c.Ethash
+	case c.Clique != nil:
+		return c.Clique
+	case c.POSA != nil:
+		return c.POSA
+	}
+	return "unknown"
+}
+
+// String implements the fmt.Stringer interface.
+func (c *ChainConfig) String() string {
+	engine := c.engine()
+	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Petersburg: %v Istanbul: %v, Muir Glacier: %v, Berlin: %v, cve_2021_39137Block:%v, Ishikari: %v, IshikariPatch001: %v, IshikariPatch002: %v, London: %v, MergeNetsplit: %v, YOLO v3: %v, EWASM: %v, Engine: %v}",
+		c.ChainID,
+		c.HomesteadBlock,
+		c.DAOForkBlock,
+		c.DAOForkSupport,
+		c.EIP150Block,
+		c.EIP155Block,
+		c.EIP158Block,
+		c.ByzantiumBlock,
+		c.ConstantinopleBlock,
+		c.PetersburgBlock,
+		c.IstanbulBlock,
+		c.MuirGlacierBlock,
+		c.BerlinBlock,
+		c.CVE_2021_39137Block,
+		c.IshikariBlock,
+		c.IshikariPatch001Block,
+		c.IshikariPatch002Block,
+		c.LondonBlock,
+		c.MergeNetsplitBlock,
+		c.YoloV3Block,
+		c.EWASMBlock,
+		engine,
+	)
+}
+
+// ValidTxChainID returns whether a transaction carrying the given chain ID is
+// acceptable at block num: either replay protection isn't active yet, or the
+// chain ID matches the config's. A nil txChainID never matches, unprotected
+// transactions have to be special cased by the caller.
+func (c *ChainConfig) ValidTxChainID(txChainID *big.Int, num *big.Int) bool {
+	if !c.IsEIP155(num) {
+		return true
+	}
+	return txChainID != nil && c.ChainID != nil && txChainID.Cmp(c.ChainID) == 0
+}
+
+// IsNil reports whether the config is nil. The fork predicates (Is*) and Rules
+// treat a nil config as having no forks at all instead of panicking. Rules logs a
+// warning the first time this happens, since it usually means a config failed
+// to load and the error was lost on the way.
+func (c *ChainConfig) IsNil() bool {
+	return c == nil
+}
+
+// IsHomestead returns whether num is either equal to the homestead block or greater.
+func (c *ChainConfig) IsHomestead(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	return isForked(c.HomesteadBlock, num)
+}
+
+// DAODisabled returns whether the DAO fork logic is disabled altogether, i.e. no
+// DAO fork block is configured. The DAOForkSupport flag is irrelevant then.
+func (c *ChainConfig) DAODisabled() bool {
+	return c.DAOForkBlock == nil
+}
+
+// IsDAOFork returns whether num is either equal to the DAO fork block or greater.
+func (c *ChainConfig) IsDAOFork(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	return isForked(c.DAOForkBlock, num)
+}
+
+// IsEIP150 returns whether num is either equal to the EIP150 fork block or greater.
+func (c *ChainConfig) IsEIP150(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	return isForked(c.EIP150Block, num)
+}
+
+// IsEIP155 returns whether num is either equal to the EIP155 fork block or greater.
+func (c *ChainConfig) IsEIP155(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	return isForked(c.EIP155Block, num)
+}
+
+// IsEIP158 returns whether num is either equal to the EIP158 fork block or greater.
+func (c *ChainConfig) IsEIP158(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	return isForked(c.EIP158Block, num)
+}
+
+// IsByzantium returns whether num is either equal to the Byzantium fork block or greater.
+func (c *ChainConfig) IsByzantium(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	return isForked(c.ByzantiumBlock, num)
+}
+
+// IsConstantinople returns whether num is either equal to the Constantinople fork block or greater.
+func (c *ChainConfig) IsConstantinople(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	return isForked(c.ConstantinopleBlock, num)
+}
+
+// IsMuirGlacier returns whether num is either equal to the Muir Glacier (EIP-2384) fork block or greater.
+func (c *ChainConfig) IsMuirGlacier(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	return isForked(c.MuirGlacierBlock, num)
+}
+
+// IsPetersburg returns whether num is either
+// - equal to or greater than the PetersburgBlock fork block,
+// - OR is nil, and Constantinople is active
+func (c *ChainConfig) IsPetersburg(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	return isForked(c.PetersburgBlock, num) || c.PetersburgBlock == nil && isForked(c.ConstantinopleBlock, num)
+}
+
+// IsIstanbul returns whether num is either equal to the Istanbul fork block or greater.
+func (c *ChainConfig) IsIstanbul(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	return isForked(c.IstanbulBlock, num)
+}
+
+// IsBerlin returns whether num is either equal to the Berlin fork block or greater.
+func (c *ChainConfig) IsBerlin(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	return isForked(c.BerlinBlock, num) || isForked(c.YoloV3Block, num)
+}
+
+// IsEIP2929 returns whether num is at or past the activation of EIP-2929 (gas
+// cost increases for state access opcodes), which is part of Berlin.
+func (c *ChainConfig) IsEIP2929(num *big.Int) bool {
+	return c.IsBerlin(num)
+}
+
+// IsEIP2718 returns whether num is at or past the activation of EIP-2718 (typed
+// transaction envelopes), which is part of Berlin.
+func (c *ChainConfig) IsEIP2718(num *big.Int) bool {
+	return c.IsBerlin(num)
+}
+
+// IsEIP2930 returns whether num is at or past the activation of EIP-2930 (access
+// list transactions), which is part of Berlin.
+func (c *ChainConfig) IsEIP2930(num *big.Int) bool {
+	return c.IsBerlin(num)
+}
+
+// IsLondon returns whether num is either equal to the London fork block or
+// greater. Oychain folds the London era fee mechanics into Berlin, so unless a
+// dedicated LondonBlock is configured, London is equivalent to Berlin.
+func (c *ChainConfig) IsLondon(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	if c.LondonBlock != nil {
+		return isForked(c.LondonBlock, num)
+	}
+	return c.IsBerlin(num)
+}
+
+// IsPostMerge returns whether the chain transitioned to proof-of-stake by block
+// num with total difficulty td, i.e. whether td reached the terminal total
+// difficulty, or num reached the merge netsplit block. Mirroring upstream, both
+// are inert unless TerminalTotalDifficulty is set.
+func (c *ChainConfig) IsPostMerge(num *big.Int, td *big.Int) bool {
+	if c == nil || c.TerminalTotalDifficulty == nil {
+		return false
+	}
+	if td != nil && td.Cmp(c.TerminalTotalDifficulty) >= 0 {
+		return true
+	}
+	return isForked(c.MergeNetsplitBlock, num)
+}
+
+// IsEWASM returns whether num represents a block number after the EWASM fork
+func (c *ChainConfig) IsEWASM(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	return isForked(c.EWASMBlock, num)
+}
+
+// is Ishikari hardfork enabled ?
+func (c *ChainConfig) IsKCCIshikari(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	return isForked(c.IshikariBlock, num)
+}
+
+// IsCVE202139137 returns whether num is past the CVE_2021_39137Block "fake" fork,
+// i.e. whether the fixed implementation of the call return data applies.
+//
+// Unlike the real forks the boundary is exclusive: the fork block itself still
+// has to be processed with the vulnerable implementation, and the fix applies
+// from the next block on. A nil fork block means the chain never ran vulnerable
+// code, so the fix always applies.
+//
+// The fork is intentionally left out of CheckConfigForkOrder and of the forkid
+// computation, it must behave as if it does not exist.
+// See more in: core/vm/instructions_kcc_issue_9.go
+func (c *ChainConfig) IsCVE202139137(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	if c.CVE_2021_39137Block == nil {
+		return true
+	}
+	return num != nil && c.CVE_2021_39137Block.Cmp(num) < 0
+}
+
+// IshikariFullyActivated returns whether the whole Ishikari release, i.e. the
+// Ishikari fork and both of its patches, is active at num.
+func (c *ChainConfig) IshikariFullyActivated(num *big.Int) bool {
+	return c.IsKCCIshikari(num) && isForked(c.IshikariPatch001Block, num) && isForked(c.IshikariPatch002Block, num)
+}
+
+// IshikariPartiallyActivated returns whether the Ishikari fork is active at num
+// while some of its patches are still pending. This never happens on mainnet,
+// where all of them activated at the same block.
+func (c *ChainConfig) IshikariPartiallyActivated(num *big.Int) bool {
+	return c.IsKCCIshikari(num) && !c.IshikariFullyActivated(num)
+}
+
+// IsPreIshikari returns whether the Ishikari fork is scheduled and num is
+// strictly before it.
+func (c *ChainConfig) IsPreIshikari(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	if num == nil || c.IshikariBlock == nil {
+		return false
+	}
+	return num.Cmp(c.IshikariBlock) < 0
+}
+
+// IsWithinIshikariWindow returns whether num is within the first window blocks
+// after the Ishikari activation, i.e. in [IshikariBlock, IshikariBlock+window).
+func (c *ChainConfig) IsWithinIshikariWindow(num *big.Int, window uint64) bool {
+	if c == nil {
+		return false
+	}
+	if !isForked(c.IshikariBlock, num) {
+		return false
+	}
+	end := new(big.Int).Add(c.IshikariBlock, new(big.Int).SetUint64(window))
+	return num.Cmp(end) < 0
+}
+
+// IsValidatorSetFrozen returns whether num is within the first POSA epoch after
+// the Ishikari activation, i.e. in [IshikariBlock, IshikariBlock+Epoch), while
+// the validators contract is bootstrapping and the validator set can't change.
+func (c *ChainConfig) IsValidatorSetFrozen(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	if c.POSA == nil {
+		return false
+	}
+	return c.IsWithinIshikariWindow(num, c.POSA.Epoch)
+}
+
+// is the block number "num" when Ishikari hardfork happens ?
+func (c *ChainConfig) IsIshikariHardforkBlock(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	if num == nil || c.IshikariBlock == nil {
+		return false
+	}
+	return num.Cmp(c.IshikariBlock) == 0
+}
+
+// is the block number "num" when IshikariPatch001 hardfork happens ?
+func (c *ChainConfig) IsIshikariPatch001HardforkBlock(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	if num == nil || c.IshikariPatch001Block == nil {
+		return false
+	}
+	return num.Cmp(c.IshikariPatch001Block) == 0
+}
+
+// is the block number "num" when IshikariPatch002 hardfork happens ?
+func (c *ChainConfig) IsIshikariPatch002HardforkBlock(num *big.Int) bool {
+	if c == nil {
+		return false
+	}
+	if num == nil || c.IshikariPatch002Block == nil {
+		return false
+	}
+	return num.Cmp(c.IshikariPatch002Block) == 0
+}
+
+// configFork is a block number based fork of a chain config, giving access to
+// the underlying config field.
+type configFork struct {
+	name      string    // canonical fork name, the JSON key without the "Block" suffix
+	block     **big.Int // the fork block field of the config
+	synthetic bool      // whether the fork is a "fake" one, hidden from fork ordering and forkid
+}
+
+// forks returns all the block number based forks of the config in declaration
+// order, including the synthetic ones.
+func (c *ChainConfig) forks() []configFork {
+	return []configFork{
+		{name: "homestead", block: &c.HomesteadBlock},
+		{name: "daoFork", block: &c.DAOForkBlock},
+		{name: "eip150", block: &c.EIP150Block},
+		{name: "eip155", block: &c.EIP155Block},
+		{name: "eip158", block: &c.EIP158Block},
+		{name: "byzantium", block: &c.ByzantiumBlock},
+		{name: "constantinople", block: &c.ConstantinopleBlock},
+		{name: "petersburg", block: &c.PetersburgBlock},
+		{name: "istanbul", block: &c.IstanbulBlock},
+		{name: "muirGlacier", block: &c.MuirGlacierBlock},
+		{name: "berlin", block: &c.BerlinBlock},
+		{name: "cve_2021_39137", block: &c.CVE_2021_39137Block, synthetic: true},
+		{name: "ishikari", block: &c.IshikariBlock},
+		{name: "ishikariPatch001", block: &c.IshikariPatch001Block},
+		{name: "ishikariPatch002", block: &c.IshikariPatch002Block},
+		{name: "london", block: &c.LondonBlock},
+		{name: "mergeNetsplit", block: &c.MergeNetsplitBlock},
+		{name: "yoloV3", block: &c.YoloV3Block},
+		{name: "ewasm", block: &c.EWASMBlock},
+	}
+}
+
+// fork looks up a fork by name, which may be given either in its canonical form
+// ("ishikari") or as its JSON key ("ishikariBlock").
+func (c *ChainConfig) fork(name string) (configFork, bool) {
+	name = strings.TrimSuffix(name, "Block")
+	for _, fork := range c.forks() {
+		if fork.name == name {
+			return fork, true
+		}
+	}
+	return configFork{}, false
+}
+
+// IsSyntheticFork reports whether the named fork is a "fake" fork, i.e. a block
+// number based switch patching a past consensus issue rather than a real network
+// upgrade. Synthetic forks take no part in fork ordering nor in the fork ID. The
+// name may be given either in its canonical form or as its JSON key.
+func (c *ChainConfig) IsSyntheticFork(name string) bool {
+	if c == nil {
+		return false
+	}
+	fork, ok := c.fork(name)
+	return ok && fork.synthetic
+}
+
+// IsGenesisFork reports whether the named fork is part of the genesis ruleset,
+// i.e. scheduled at block 0. The name may be given either in its canonical form
+// or as its JSON key.
+func (c *ChainConfig) IsGenesisFork(name string) bool {
+	if c == nil {
+		return false
+	}
+	fork, ok := c.fork(name)
+	return ok && *fork.block != nil && (*fork.block).Sign() == 0
+}
+
+// FirstFork returns the canonical name and block of the earliest configured
+// fork, skipping synthetic ones. Forks sharing the lowest block resolve to the
+// first one in fork order.
+func (c *ChainConfig) FirstFork() (name string, block *big.Int, ok bool) {
+	for _, fork := range c.forks() {
+		if fork.synthetic || *fork.block == nil {
+			continue
+		}
+		if block == nil || (*fork.block).Cmp(block) < 0 {
+			name, block, ok = fork.name, *fork.block, true
+		}
+	}
+	return name, block, ok
+}
+
+// LastFork returns the canonical name and block of the latest configured fork,
+// skipping synthetic ones. Forks sharing the highest block resolve to the last
+// one in fork order.
+func (c *ChainConfig) LastFork() (name string, block *big.Int, ok bool) {
+	for _, fork := range c.forks() {
+		if fork.synthetic || *fork.block == nil {
+			continue
+		}
+		if block == nil || (*fork.block).Cmp(block) >= 0 {
+			name, block, ok = fork.name, *fork.block, true
+		}
+	}
+	return name, block, ok
+}
+
+// ForkName returns the canonical name of the ruleset era at block num, i.e. the
+// latest scheduled non-synthetic fork active at num, or "genesis" if none is.
+// Forks sharing the activation block resolve to the last one in fork order, and
+// forks active only implicitly (like Petersburg following Constantinople) are
+// not considered, so the result is a stable label for metrics.
+func (c *ChainConfig) ForkName(num *big.Int) string {
+	var (
+		name  = "genesis"
+		block *big.Int
+	)
+	for _, fork := range c.forks() {
+		if fork.synthetic || !isForked(*fork.block, num) {
+			continue
+		}
+		if block == nil || (*fork.block).Cmp(block) >= 0 {
+			name, block = fork.name, *fork.block
+		}
+	}
+	return name
+}
+
+// ForkActivationBlocks returns the activation block of every configured fork,
+// keyed by canonical fork name, e.g. for block explorers to tag fork blocks.
+// Synthetic forks and blocks not fitting into an uint64 are skipped.
+func (c *ChainConfig) ForkActivationBlocks() map[string]uint64 {
+	blocks := make(map[string]uint64)
+	for _, fork := range c.forks() {
+		if fork.synthetic || *fork.block == nil || !(*fork.block).IsUint64() {
+			continue
+		}
+		blocks[fork.name] = (*fork.block).Uint64()
+	}
+	return blocks
+}
+
+// ForkBlockByName returns the activation block of the named fork (nil if not
+// scheduled), and whether the name denotes a known block number based fork. The
+// name may be given either in its canonical form or as its JSON key. Synthetic
+// forks like "cve_2021_39137" are recognized too.
+func (c *ChainConfig) ForkBlockByName(name string) (*big.Int, bool) {
+	fork, ok := c.fork(name)
+	if !ok {
+		return nil, false
+	}
+	return *fork.block, true
+}
+
+// RequireFork returns an error if the named fork isn't active at head, letting
+// code paths implementing fork specific behavior fail fast when reached under
+// the wrong rules.
+func (c *ChainConfig) RequireFork(name string, head uint64) error {
+	fork, ok := c.fork(name)
+	if !ok {
+		return fmt.Errorf("unknown fork %q", name)
+	}
+	num := new(big.Int).SetUint64(head)
+	if !c.isForkActive(fork, num) {
+		if *fork.block == nil {
+			return fmt.Errorf("fork %s required at block %d, but not scheduled", fork.name, head)
+		}
+		return fmt.Errorf("fork %s required at block %d, but only active from block %v", fork.name, head, *fork.block)
+	}
+	return nil
+}
+
+// isForkActive returns whether the fork is active at num, honoring the special
+// activation rules of some forks (implicit Petersburg, YoloV3 aliasing Berlin,
+// the exclusive CVE_2021_39137 boundary).
+func (c *ChainConfig) isForkActive(fork configFork, num *big.Int) bool {
+	switch fork.name {
+	case "petersburg":
+		return c.IsPetersburg(num)
+	case "berlin":
+		return c.IsBerlin(num)
+	case "london":
+		return c.IsLondon(num)
+	case "cve_2021_39137":
+		return c.IsCVE202139137(num)
+	}
+	return isForked(*fork.block, num)
+}
+
+// UnreachableForks returns the forks sharing their activation block with a fork
+// later in fork order, i.e. the forks whose rules are never in effect on their
+// own. This may be intentional (like the Ishikari fork and its patches), but in
+// private configs it's likely a mistake. Forks active at genesis are ignored,
+// since skipping the historical rulesets there is the norm.
+func (c *ChainConfig) UnreachableForks() []string {
+	var (
+		forks = c.forks()
+		names []string
+	)
+	for i, fork := range forks {
+		if fork.synthetic || *fork.block == nil || (*fork.block).Sign() == 0 {
+			continue
+		}
+		for _, later := range forks[i+1:] {
+			if !later.synthetic && *later.block != nil && (*later.block).Cmp(*fork.block) == 0 {
+				names = append(names, fork.name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+// forkEIPs lists the EIPs introduced by each fork. Forks not implementing any
+// EIP of their own (DAO, the Ishikari forks, the synthetic forks) are absent, as
+// is London, whose EIPs oychain doesn't implement.
+var forkEIPs = map[string][]int{
+	"homestead":      {2, 7, 8},
+	"eip150":         {150},
+	"eip155":         {155},
+	"eip158":         {160, 161, 170},
+	"byzantium":      {100, 140, 196, 197, 198, 211, 214, 649, 658},
+	"constantinople": {145, 1014, 1052, 1234, 1283},
+	"istanbul":       {152, 1108, 1344, 1884, 2028, 2200},
+	"muirGlacier":    {2384},
+	"berlin":         {2565, 2718, 2929, 2930},
+}
+
+// ActiveEIPs returns the sorted numbers of the EIPs in effect at block num, as
+// derived from the active forks.
+func (c *ChainConfig) ActiveEIPs(num *big.Int) []int {
+	active := make(map[int]bool)
+	for _, fork := range c.forks() {
+		if eips, ok := forkEIPs[fork.name]; ok && c.isForkActive(fork, num) {
+			for _, eip := range eips {
+				active[eip] = true
+			}
+		}
+	}
+	// Petersburg is Constantinople without the EIP-1283 net gas metering
+	if c.IsPetersburg(num) {
+		delete(active, 1283)
+	}
+	eips := make([]int, 0, len(active))
+	for eip := range active {
+		eips = append(eips, eip)
+	}
+	sort.Ints(eips)
+	return eips
+}
+
+// Sync modes a node may be required to run in, from least to most demanding.
+const (
+	SyncModeSnap    = "snap"
+	SyncModeFull    = "full"
+	SyncModeArchive = "archive"
+)
+
+// syncModeRank orders the sync modes by the data they retain.
+var syncModeRank = map[string]int{SyncModeSnap: 0, SyncModeFull: 1, SyncModeArchive: 2}
+
+// forkSyncModes lists the forks requiring more than a full node once active.
+// None does yet, it's the place to gate future forks on the sync mode.
+var forkSyncModes = map[string]string{}
+
+// MinimumSyncMode returns the least demanding sync mode a node must run in to
+// process blocks at num, according to the forks active by then.
+func (c *ChainConfig) MinimumSyncMode(num *big.Int) string {
+	mode := SyncModeFull
+	for _, fork := range c.forks() {
+		required, ok := forkSyncModes[fork.name]
+		if ok && syncModeRank[required] > syncModeRank[mode] && c.isForkActive(fork, num) {
+			mode = required
+		}
+	}
+	return mode
+}
+
+// ImminentForks returns the names of the forks scheduled within the next within
+// blocks after head, i.e. in (head, head+within], in declaration order. Nodes
+// can use it to warn operators about upcoming forks their client must support.
+func (c *ChainConfig) ImminentForks(head uint64, within uint64) []string {
+	var (
+		from  = new(big.Int).SetUint64(head)
+		until = new(big.Int).Add(from, new(big.Int).SetUint64(within))
+		names []string
+	)
+	for _, fork := range c.forks() {
+		if fork.synthetic || *fork.block == nil {
+			continue
+		}
+		if (*fork.block).Cmp(from) > 0 && (*fork.block).Cmp(until) <= 0 {
+			names = append(names, fork.name)
+		}
+	}
+	return names
+}
+
+// ForkETA is a fork of a fork timeline, with its estimated activation time.
+type ForkETA struct {
+	Name  string    // Canonical fork name
+	Block uint64    // Activation block of the fork
+	ETA   time.Time // Estimated activation time, zero if already passed
+}
+
+// ForkTimeline returns the configured forks in fork order, estimating the
+// activation time of the future ones from the head and the block period of the
+// consensus engine. Forks already passed at head have a zero ETA, as do all the
+// forks if the engine has no fixed block period. Synthetic forks are skipped.
+func (c *ChainConfig) ForkTimeline(head uint64, headTime time.Time) []ForkETA {
+	period, _ := c.BlockPeriod()
+
+	var timeline []ForkETA
+	for _, fork := range c.forks() {
+		if fork.synthetic || *fork.block == nil || !(*fork.block).IsUint64() {
+			continue
+		}
+		entry := ForkETA{Name: fork.name, Block: (*fork.block).Uint64()}
+		if blocks := entry.Block - head; entry.Block > head && period > 0 && blocks <= math.MaxInt64/uint64(time.Second)/period {
+			entry.ETA = headTime.Add(time.Duration(blocks*period) * time.Second)
+		}
+		timeline = append(timeline, entry)
+	}
+	return timeline
+}
+
+// ForkTransition is a fork activation crossed while importing a range of blocks.
+type ForkTransition struct {
+	Name  string // Canonical fork name
+	Block uint64 // Activation block of the fork
+}
+
+// ForkTransitions returns the forks activating in the block range (from, to], by
+// activation block and in fork order within the same block, so that block import
+// can run one-off migrations for every fork it crosses. Synthetic forks are
+// skipped.
+func (c *ChainConfig) ForkTransitions(from, to uint64) []ForkTransition {
+	var transitions []ForkTransition
+	for _, fork := range c.forks() {
+		if fork.synthetic || *fork.block == nil || !(*fork.block).IsUint64() {
+			continue
+		}
+		if block := (*fork.block).Uint64(); block > from && block <= to {
+			transitions = append(transitions, ForkTransition{Name: fork.name, Block: block})
+		}
+	}
+	sort.SliceStable(transitions, func(i, j int) bool {
+		return transitions[i].Block < transitions[j].Block
+	})
+	return transitions
+}
+
+// ForkProgress returns the name of the next fork after head and how far head has
+// progressed towards it from the previous fork (or genesis if no fork activated
+// yet), as a fraction between 0 and 1. Forks activated at the same block resolve
+// to the first one in fork order, synthetic forks are skipped. It returns false
+// if no fork is scheduled after head.
+func (c *ChainConfig) ForkProgress(head uint64) (name string, progress float64, ok bool) {
+	var prev, next uint64
+	for _, fork := range c.forks() {
+		if fork.synthetic || *fork.block == nil || !(*fork.block).IsUint64() {
+			continue
+		}
+		switch block := (*fork.block).Uint64(); {
+		case block <= head:
+			if block > prev {
+				prev = block
+			}
+		case !ok || block < next:
+			name, next, ok = fork.name, block, true
+		}
+	}
+	if !ok {
+		return "", 0, false
+	}
+	return name, float64(head-prev) / float64(next-prev), true
+}
+
+// ForkDistance returns the number of blocks from head to the activation of the
+// named fork, negative if the fork is already past. Distances beyond the int64
+// range saturate at its bounds. It returns false if the fork is unknown or not
+// scheduled.
+func (c *ChainConfig) ForkDistance(name string, head uint64) (int64, bool) {
+	fork, ok := c.fork(name)
+	if !ok || *fork.block == nil {
+		return 0, false
+	}
+	distance := new(big.Int).Sub(*fork.block, new(big.Int).SetUint64(head))
+	switch {
+	case distance.IsInt64():
+		return distance.Int64(), true
+	case distance.Sign() > 0:
+		return math.MaxInt64, true
+	default:
+		return math.MinInt64, true
+	}
+}
+
+// BlockTimeRef is a reference point of a chain, pairing a block with its time.
+type BlockTimeRef struct {
+	Block uint64
+	Time  time.Time
+}
+
+// EstimateForkTime extrapolates the activation time of the named fork from the
+// reference block, forward or backward, assuming every block took exactly the
+// block period of the consensus engine. It returns false if the fork isn't
+// scheduled, or if the engine has no fixed block period.
+func (c *ChainConfig) EstimateForkTime(name string, ref BlockTimeRef) (time.Time, bool) {
+	fork, ok := c.fork(name)
+	if !ok || *fork.block == nil || !(*fork.block).IsUint64() {
+		return time.Time{}, false
+	}
+	period, ok := c.BlockPeriod()
+	if !ok || period == 0 {
+		return time.Time{}, false
+	}
+	var (
+		block  = (*fork.block).Uint64()
+		blocks = block - ref.Block
+		sign   = time.Duration(1)
+	)
+	if block < ref.Block {
+		blocks, sign = ref.Block-block, -1
+	}
+	if blocks > math.MaxInt64/uint64(time.Second)/period {
+		return time.Time{}, false
+	}
+	return ref.Time.Add(sign * time.Duration(blocks*period) * time.Second), true
+}
+
+// PruneFutureForks returns a copy of the config with all the forks scheduled
+// after head unset, e.g. to export a snapshot without leaking future fork plans.
+// Since forks are ordered, pruning from the top always retains a valid order.
+func (c *ChainConfig) PruneFutureForks(head uint64) *ChainConfig {
+	var (
+		cpy   = c.Clone()
+		bhead = new(big.Int).SetUint64(head)
+	)
+	for _, fork := range cpy.forks() {
+		if *fork.block != nil && (*fork.block).Cmp(bhead) > 0 {
+			cpy.unsetFork(fork)
+		}
+	}
+	return cpy
+}
+
+// unsetFork unschedules the fork, dropping the client versions and disabled
+// opcodes tied to it so the config remains valid. It returns whether the fork
+// was scheduled at all.
+func (c *ChainConfig) unsetFork(fork configFork) bool {
+	if *fork.block == nil {
+		return false
+	}
+	*fork.block = nil
+	for name := range c.MinClientVersions {
+		if f, _ := c.fork(name); f.name == fork.name {
+			delete(c.MinClientVersions, name)
+		}
+	}
+	for name := range c.DisabledOpcodes {
+		if f, _ := c.fork(name); f.name == fork.name {
+			delete(c.DisabledOpcodes, name)
+		}
+	}
+	return true
+}
+
+// DisableFork returns a copy of the config with the named fork unset, e.g. to
+// test what if a fork never activated. Since a mandatory fork can't be skipped,
+// disabling one cascades to all the forks ordered after it, and disabling Berlin
+// also disables its YoloV3 alias. Optional and unordered forks are disabled on
+// their own. Client versions and disabled opcodes tied to the unset forks are
+// dropped like in PruneFutureForks. The canonical names of the forks actually
+// unset are returned.
+func (c *ChainConfig) DisableFork(name string) (*ChainConfig, []string, error) {
+	cpy := c.Clone()
+	target, ok := cpy.fork(name)
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown fork %q", name)
+	}
+	var disabled []string
+	disable := func(fork configFork) {
+		if cpy.unsetFork(fork) {
+			disabled = append(disabled, fork.name)
+		}
+	}
+	disable(target)
+
+	// Cascade to the forks ordered after a mandatory one
+	order := c.forkOrder()
+	for i, fork := range order {
+		if strings.TrimSuffix(fork.name, "Block") != target.name || fork.optional {
+			continue
+		}
+		for _, later := range order[i+1:] {
+			f, _ := cpy.fork(later.name)
+			disable(f)
+		}
+	}
+	if target.name == "berlin" {
+		f, _ := cpy.fork("yoloV3")
+		disable(f)
+	}
+	if err := cpy.CheckConfigForkOrder(); err != nil {
+		return nil, nil, err
+	}
+	return cpy, disabled, nil
+}
+
+// SetForkChecked returns a copy of the config with the named fork rescheduled to
+// block (nil unsets it). The change is rejected with a *ConfigCompatError if it
+// would alter the chain up to head, i.e. if CheckCompatible would refuse it.
+func (c *ChainConfig) SetForkChecked(name string, block *big.Int, head uint64) (*ChainConfig, error) {
+	cpy := c.Clone()
+	fork, ok := cpy.fork(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown fork %q", name)
+	}
+	*fork.block = cloneBig(block)
+
+	if err := c.CheckCompatible(cpy, head); err != nil {
+		return nil, err
+	}
+	return cpy, nil
+}
+
+// MergeForkOverrides returns a copy of the config with the named fork blocks
+// replaced, e.g. to layer an operator's overrides over a built-in config. A nil
+// block unsets the fork. The result must still have a valid fork order.
+func (c *ChainConfig) MergeForkOverrides(overrides map[string]*big.Int) (*ChainConfig, error) {
+	cpy := c.Clone()
+	for name, block := range overrides {
+		fork, ok := cpy.fork(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown fork %q", name)
+		}
+		*fork.block = cloneBig(block)
+	}
+	if err := cpy.CheckConfigForkOrder(); err != nil {
+		return nil, err
+	}
+	return cpy, nil
+}
+
+// UpgradeForksAtomically returns a copy of the config with all the named fork
+// blocks replaced, for governance driven upgrades needing all-or-nothing
+// semantics. Unlike MergeForkOverrides, the whole result is validated (fork
+// order, POSA parameters and the rest) and any failure rejects all the changes.
+// The config itself is never modified.
+func (c *ChainConfig) UpgradeForksAtomically(changes map[string]*big.Int) (*ChainConfig, error) {
+	cpy, err := c.MergeForkOverrides(changes)
+	if err != nil {
+		return nil, err
+	}
+	if err := cpy.Validate(); err != nil {
+		return nil, err
+	}
+	return cpy, nil
+}
+
+// Equal reports whether the two configs are identical, comparing numbers by
+// value and treating nil and empty collections alike.
+func (c *ChainConfig) Equal(other *ChainConfig) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	if !configNumEqual(c.ChainID, other.ChainID) || c.DAOForkSupport != other.DAOForkSupport || c.EIP150Hash != other.EIP150Hash {
+		return false
+	}
+	theirs := other.forks()
+	for i, fork := range c.forks() {
+		if !configNumEqual(*fork.block, *theirs[i].block) {
+			return false
+		}
+	}
+	if !uint64PtrEqual(c.BaseFeeChangeDenominator, other.BaseFeeChangeDenominator) || !uint64PtrEqual(c.ElasticityMultiplier, other.ElasticityMultiplier) {
+		return false
+	}
+	if !uint64PtrEqual(c.InitialGasLimit, other.InitialGasLimit) || !uint64PtrEqual(c.GasTarget, other.GasTarget) {
+		return false
+	}
+	if !configNumEqual(c.TerminalTotalDifficulty, other.TerminalTotalDifficulty) {
+		return false
+	}
+	if !clientVersionsEqual(c.MinClientVersions, other.MinClientVersions) {
+		return false
+	}
+	if !disabledOpcodesEqual(c.DisabledOpcodes, other.DisabledOpcodes) {
+		return false
+	}
+	if !addressesEqual(c.FeeExemptAddresses, other.FeeExemptAddresses) {
+		return false
+	}
+	return c.ConfigVersion == other.ConfigVersion && engineEqual(c, other)
+}
+
+// Diff describes the differences between the config and other, one line per
+// differing field in the form "field: old -> new". Nil values are shown as
+// <nil>. Engines are compared as a whole.
+func (c *ChainConfig) Diff(other *ChainConfig) []string {
+	var diff []string
+	add := func(field string, old, new interface{}) {
+		diff = append(diff, fmt.Sprintf("%s: %v -> %v", field, old, new))
+	}
+	if !configNumEqual(c.ChainID, other.ChainID) {
+		add("chainId", c.ChainID, other.ChainID)
+	}
+	if c.DAOForkSupport != other.DAOForkSupport {
+		add("daoForkSupport", c.DAOForkSupport, other.DAOForkSupport)
+	}
+	if c.EIP150Hash != other.EIP150Hash {
+		add("eip150Hash", c.EIP150Hash.Hex(), other.EIP150Hash.Hex())
+	}
+	theirs := other.forks()
+	for i, fork := range c.forks() {
+		if !configNumEqual(*fork.block, *theirs[i].block) {
+			add(fork.name+"Block", *fork.block, *theirs[i].block)
+		}
+	}
+	if !configNumEqual(c.TerminalTotalDifficulty, other.TerminalTotalDifficulty) {
+		add("terminalTotalDifficulty", c.TerminalTotalDifficulty, other.TerminalTotalDifficulty)
+	}
+	for _, field := range []struct {
+		name     string
+		old, new *uint64
+	}{
+		{"baseFeeChangeDenominator", c.BaseFeeChangeDenominator, other.BaseFeeChangeDenominator},
+		{"elasticityMultiplier", c.ElasticityMultiplier, other.ElasticityMultiplier},
+		{"initialGasLimit", c.InitialGasLimit, other.InitialGasLimit},
+		{"gasTarget", c.GasTarget, other.GasTarget},
+	} {
+		if !uint64PtrEqual(field.old, field.new) {
+			add(field.name, optionalUint64String(field.old), optionalUint64String(field.new))
+		}
+	}
+	if !clientVersionsEqual(c.MinClientVersions, other.MinClientVersions) {
+		add("minClientVersions", c.MinClientVersions, other.MinClientVersions)
+	}
+	if !disabledOpcodesEqual(c.DisabledOpcodes, other.DisabledOpcodes) {
+		add("disabledOpcodes", c.DisabledOpcodes, other.DisabledOpcodes)
+	}
+	if !addressesEqual(c.FeeExemptAddresses, other.FeeExemptAddresses) {
+		add("feeExemptAddresses", c.FeeExemptAddresses, other.FeeExemptAddresses)
+	}
+	if c.ConfigVersion != other.ConfigVersion {
+		add("configVersion", c.ConfigVersion, other.ConfigVersion)
+	}
+	if !engineEqual(c, other) {
+		add("engine", c.engine(), other.engine())
+	}
+	return diff
+}
+
+// optionalUint64String formats an optional uint64, showing nil as <nil>.
+func optionalUint64String(x *uint64) string {
+	if x == nil {
+		return "<nil>"
+	}
+	return strconv.FormatUint(*x, 10)
+}
+
+// clientVersionsEqual reports whether the two minimum client version sets are
+// identical, treating nil and empty alike.
+func clientVersionsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for fork, version := range a {
+		if theirs, ok := b[fork]; !ok || theirs != version {
+			return false
+		}
+	}
+	return true
+}
+
+// disabledOpcodesEqual reports whether the two disabled opcode sets are
+// identical, treating nil and empty alike.
+func disabledOpcodesEqual(a, b map[string][]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for fork, ops := range a {
+		if theirs, ok := b[fork]; !ok || !stringsEqual(ops, theirs) {
+			return false
+		}
+	}
+	return true
+}
+
+// stringsEqual reports whether the two string lists are identical.
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// uint64PtrEqual reports whether two optional uint64s are equal.
+func uint64PtrEqual(x, y *uint64) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return *x == *y
+}
+
+// EqualAtHead reports whether the two configs run the same ruleset up to head,
+// e.g. to check whether two peers are compatible at the current head. It compares
+// the chain ID, EIP150 hash, consensus engine and the forks reached by head on
+// either side; synthetic forks and forks neither config reached are ignored.
+func (c *ChainConfig) EqualAtHead(other *ChainConfig, head uint64) bool {
+	if !configNumEqual(c.ChainID, other.ChainID) || c.EIP150Hash != other.EIP150Hash {
+		return false
+	}
+	if !engineEqual(c, other) {
+		return false
+	}
+	var (
+		bhead  = new(big.Int).SetUint64(head)
+		theirs = other.forks()
+	)
+	for i, fork := range c.forks() {
+		if fork.synthetic {
+			continue
+		}
+		if isForked(*fork.block, bhead) || isForked(*theirs[i].block, bhead) {
+			if !configNumEqual(*fork.block, *theirs[i].block) {
+				return false
+			}
+		}
+	}
+	if c.IsDAOFork(bhead) && c.DAOForkSupport != other.DAOForkSupport {
+		return false
+	}
+	return true
+}
+
+// Overlaps reports whether the two configs share the chain ID and the set of
+// fork blocks activated up to head, in which case nodes of both chains would
+// advertise the same fork ID on a shared genesis and may peer with each other.
+// Forks at genesis and synthetic forks don't contribute to the fork ID and are
+// ignored, as are the engines.
+func (c *ChainConfig) Overlaps(other *ChainConfig, head uint64) bool {
+	if !configNumEqual(c.ChainID, other.ChainID) {
+		return false
+	}
+	ours, theirs := c.activeForkBlocks(head), other.activeForkBlocks(head)
+	if len(ours) != len(theirs) {
+		return false
+	}
+	for i := range ours {
+		if ours[i] != theirs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// activeForkBlocks returns the distinct non-genesis blocks at which forks got
+// activated up to head, in ascending order.
+func (c *ChainConfig) activeForkBlocks(head uint64) []uint64 {
+	var blocks []uint64
+	for _, fork := range c.forks() {
+		if fork.synthetic || *fork.block == nil || !(*fork.block).IsUint64() {
+			continue
+		}
+		if block := (*fork.block).Uint64(); block > 0 && block <= head {
+			blocks = append(blocks, block)
+		}
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i] < blocks[j] })
+
+	distinct := blocks[:0]
+	for i, block := range blocks {
+		if i == 0 || block != blocks[i-1] {
+			distinct = append(distinct, block)
+		}
+	}
+	return distinct
+}
+
+// ForkComparison is a fork of two configs set side by side, see
+// CompareForkSchedules.
+type ForkComparison struct {
+	Name        string
+	Self, Other *big.Int
+	Same        bool
+}
+
+// CompareForkSchedules lists every block number based fork, including the
+// synthetic ones, in fork order with its activation block in the config and in
+// other. It's meant for reviewing schedules, use CheckCompatible to find out
+// whether switching between them is possible.
+func (c *ChainConfig) CompareForkSchedules(other *ChainConfig) []ForkComparison {
+	var (
+		theirs      = other.forks()
+		comparisons []ForkComparison
+	)
+	for i, fork := range c.forks() {
+		comparisons = append(comparisons, ForkComparison{
+			Name:  fork.name,
+			Self:  *fork.block,
+			Other: *theirs[i].block,
+			Same:  configNumEqual(*fork.block, *theirs[i].block),
+		})
+	}
+	return comparisons
+}
+
+// engineEqual reports whether the two configs use the same consensus engine with
+// the same parameters.
+func engineEqual(a, b *ChainConfig) bool {
+	if (a.Ethash == nil) != (b.Ethash == nil) {
+		return false
+	}
+	if (a.Clique == nil) != (b.Clique == nil) || (a.Clique != nil && *a.Clique != *b.Clique) {
+		return false
+	}
+	return a.POSA.Equal(b.POSA)
+}
+
+// CheckCompatible checks whether scheduled fork transitions have been imported
+// with a mismatching chain configuration.
+func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *ConfigCompatError {
 	bhead := new(big.Int).SetUint64(height)
 
 	// Iterate checkCompatible to find the lowest conflict.
@@ -480,16 +2749,50 @@ func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *Confi
 	return lasterr
 }
 
+// CheckCompatibleBatch checks the config, as a new config, against several stored
+// configs, e.g. the ones written by different historical node versions. The
+// returned map holds the incompatibilities keyed by the index of the stored
+// config, compatible configs have no entry.
+func (c *ChainConfig) CheckCompatibleBatch(candidates []*ChainConfig, height uint64) map[int]*ConfigCompatError {
+	errs := make(map[int]*ConfigCompatError)
+	for i, stored := range candidates {
+		if err := stored.CheckCompatible(c, height); err != nil {
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+// ErrForkOrder is returned if the forks of a chain config are scheduled in an
+// unsupported order.
+var ErrForkOrder = errors.New("unsupported fork ordering")
+
 // CheckConfigForkOrder checks that we don't "skip" any forks, geth isn't pluggable enough
 // to guarantee that forks can be implemented in a different order than on official networks
 func (c *ChainConfig) CheckConfigForkOrder() error {
-	type fork struct {
-		name     string
-		block    *big.Int
-		optional bool // if true, the fork may be nil and next fork is still allowed
+	if err := c.EnsureIshikariInvariants(); err != nil {
+		return err
+	}
+	// YoloV3 is an alias of Berlin (see IsBerlin), scheduling them at different
+	// blocks is a contradiction
+	if c.BerlinBlock != nil && c.YoloV3Block != nil && c.BerlinBlock.Cmp(c.YoloV3Block) != 0 {
+		return fmt.Errorf("%w: berlinBlock enabled at %v, but yoloV3Block enabled at %v", ErrForkOrder,
+			c.BerlinBlock, c.YoloV3Block)
 	}
-	var lastFork fork
-	for _, cur := range []fork{
+	return checkForkOrder(c.forkOrder())
+}
+
+// unorderedForks are the forks exempt from the fork ordering: YoloV3 is an alias
+// of Berlin, checked separately, and EWASM is an experimental switch.
+var unorderedForks = map[string]bool{
+	"yoloV3": true,
+	"ewasm":  true,
+}
+
+// forkOrder returns the non-synthetic forks, except for the unorderedForks, in
+// their required activation order.
+func (c *ChainConfig) forkOrder() []scheduledFork {
+	return []scheduledFork{
 		{name: "homesteadBlock", block: c.HomesteadBlock},
 		{name: "daoForkBlock", block: c.DAOForkBlock, optional: true},
 		{name: "eip150Block", block: c.EIP150Block},
@@ -504,16 +2807,48 @@ func (c *ChainConfig) CheckConfigForkOrder() error {
 		{name: "ishikariBlock", block: c.IshikariBlock},
 		{name: "ishikariPatch001Block", block: c.IshikariPatch001Block},
 		{name: "ishikariPatch002Block", block: c.IshikariPatch002Block},
-	} {
+		{name: "londonBlock", block: c.LondonBlock, optional: true},
+		{name: "mergeNetsplitBlock", block: c.MergeNetsplitBlock, optional: true},
+	}
+}
+
+// EnsureIshikariInvariants checks that the Ishikari patches aren't scheduled
+// without the Ishikari fork they patch. The fork ordering would reject such a
+// config too, but far less clearly.
+func (c *ChainConfig) EnsureIshikariInvariants() error {
+	if c.IshikariBlock != nil {
+		return nil
+	}
+	if c.IshikariPatch001Block != nil {
+		return fmt.Errorf("%w: ishikariPatch001Block enabled at %v without ishikariBlock", ErrForkOrder, c.IshikariPatch001Block)
+	}
+	if c.IshikariPatch002Block != nil {
+		return fmt.Errorf("%w: ishikariPatch002Block enabled at %v without ishikariBlock", ErrForkOrder, c.IshikariPatch002Block)
+	}
+	return nil
+}
+
+// scheduledFork is a block number based fork in the fork ordering.
+type scheduledFork struct {
+	name     string
+	block    *big.Int
+	optional bool // if true, the fork may be nil and next fork is still allowed
+}
+
+// checkForkOrder checks that the forks, listed in their required activation
+// order, are scheduled monotonically without skipping a mandatory fork.
+func checkForkOrder(forks []scheduledFork) error {
+	var lastFork scheduledFork
+	for _, cur := range forks {
 		if lastFork.name != "" {
 			// Next one must be higher number
 			if lastFork.block == nil && cur.block != nil {
-				return fmt.Errorf("unsupported fork ordering: %v not enabled, but %v enabled at %v",
+				return fmt.Errorf("%w: %v not enabled, but %v enabled at %v", ErrForkOrder,
 					lastFork.name, cur.name, cur.block)
 			}
 			if lastFork.block != nil && cur.block != nil {
 				if lastFork.block.Cmp(cur.block) > 0 {
-					return fmt.Errorf("unsupported fork ordering: %v enabled at %v, but %v enabled at %v",
+					return fmt.Errorf("%w: %v enabled at %v, but %v enabled at %v", ErrForkOrder,
 						lastFork.name, lastFork.block, cur.name, cur.block)
 				}
 			}
@@ -533,7 +2868,7 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.DAOForkBlock, newcfg.DAOForkBlock, head) {
 		return newCompatError("DAO fork block", c.DAOForkBlock, newcfg.DAOForkBlock)
 	}
-	if c.IsDAOFork(head) && c.DAOForkSupport != newcfg.DAOForkSupport {
+	if !c.DAODisabled() && c.IsDAOFork(head) && c.DAOForkSupport != newcfg.DAOForkSupport {
 		return newCompatError("DAO fork support flag", c.DAOForkBlock, newcfg.DAOForkBlock)
 	}
 	if isForkIncompatible(c.EIP150Block, newcfg.EIP150Block, head) {
@@ -588,6 +2923,12 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.IshikariPatch002Block, newcfg.IshikariPatch002Block, head) {
 		return newCompatError("IshikariPatch002 fork block", c.IshikariPatch002Block, newcfg.IshikariPatch002Block)
 	}
+	if isForkIncompatible(c.LondonBlock, newcfg.LondonBlock, head) {
+		return newCompatError("London fork block", c.LondonBlock, newcfg.LondonBlock)
+	}
+	if isForkIncompatible(c.MergeNetsplitBlock, newcfg.MergeNetsplitBlock, head) {
+		return newCompatError("Merge netsplit fork block", c.MergeNetsplitBlock, newcfg.MergeNetsplitBlock)
+	}
 	return nil
 }
 
@@ -605,6 +2946,23 @@ func isForked(s, head *big.Int) bool {
 	return s.Cmp(head) <= 0
 }
 
+// cloneBig returns a copy of x, retaining nil.
+func cloneBig(x *big.Int) *big.Int {
+	if x == nil {
+		return nil
+	}
+	return new(big.Int).Set(x)
+}
+
+// cloneUint64 returns a copy of x, retaining nil.
+func cloneUint64(x *uint64) *uint64 {
+	if x == nil {
+		return nil
+	}
+	cpy := *x
+	return &cpy
+}
+
 func configNumEqual(x, y *big.Int) bool {
 	if x == nil {
 		return y == nil
@@ -615,6 +2973,10 @@ func configNumEqual(x, y *big.Int) bool {
 	return x.Cmp(y) == 0
 }
 
+// ErrConfigIncompatible is wrapped by every *ConfigCompatError, so that config
+// incompatibilities can be detected with errors.Is through any wrapping layer.
+var ErrConfigIncompatible = errors.New("incompatible chain config")
+
 // ConfigCompatError is raised if the locally-stored blockchain is initialised with a
 // ChainConfig that would alter the past.
 type ConfigCompatError struct {
@@ -623,8 +2985,25 @@ type ConfigCompatError struct {
 	StoredConfig, NewConfig *big.Int
 	// the block number to which the local chain must be rewound to correct the error
 	RewindTo uint64
+	// whether the rewind target does not fit into an uint64 and RewindTo was
+	// clamped to math.MaxUint64
+	RewindSaturated bool
+	// which of the configurations activates the fork earlier (CompatStoredAhead
+	// or CompatNewAhead), empty if both use the same fork block
+	Direction string
 }
 
+// Directions of a ConfigCompatError.
+const (
+	// CompatStoredAhead means the stored chain activated the fork earlier than
+	// the new config (or the new config drops it altogether).
+	CompatStoredAhead = "stored-ahead"
+
+	// CompatNewAhead means the new config activates the fork earlier than the
+	// stored chain did (or the stored chain never scheduled it).
+	CompatNewAhead = "new-ahead"
+)
+
 func newCompatError(what string, storedblock, newblock *big.Int) *ConfigCompatError {
 	var rew *big.Int
 	switch {
@@ -635,15 +3014,41 @@ func newCompatError(what string, storedblock, newblock *big.Int) *ConfigCompatEr
 	default:
 		rew = newblock
 	}
-	err := &ConfigCompatError{what, storedblock, newblock, 0}
+	err := &ConfigCompatError{What: what, StoredConfig: storedblock, NewConfig: newblock}
+	switch {
+	case configNumEqual(storedblock, newblock):
+	case newblock == nil || (storedblock != nil && storedblock.Cmp(newblock) < 0):
+		err.Direction = CompatStoredAhead
+	default:
+		err.Direction = CompatNewAhead
+	}
 	if rew != nil && rew.Sign() > 0 {
-		err.RewindTo = rew.Uint64() - 1
+		// A fork block beyond the uint64 range can only come from a bogus config,
+		// clamp the rewind target instead of wrapping around.
+		if rew.IsUint64() {
+			err.RewindTo = rew.Uint64() - 1
+		} else {
+			err.RewindTo = math.MaxUint64
+			err.RewindSaturated = true
+		}
 	}
 	return err
 }
 
 func (err *ConfigCompatError) Error() string {
-	return fmt.Sprintf("mismatching %s in database (have %d, want %d, rewindto %d)", err.What, err.StoredConfig, err.NewConfig, err.RewindTo)
+	msg := fmt.Sprintf("mismatching %s in database (have %d, want %d, rewindto %d)", err.What, err.StoredConfig, err.NewConfig, err.RewindTo)
+	switch err.Direction {
+	case CompatStoredAhead:
+		msg += ": the stored chain has already passed this fork; downgrade is unsupported"
+	case CompatNewAhead:
+		msg += fmt.Sprintf(": the new config activates this fork within the stored chain; rewind to block %d or resync", err.RewindTo)
+	}
+	return msg
+}
+
+// Unwrap returns ErrConfigIncompatible.
+func (err *ConfigCompatError) Unwrap() error {
+	return ErrConfigIncompatible
 }
 
 // Rules wraps ChainConfig and is merely syntactic sugar or can be used for functions
@@ -657,17 +3062,35 @@ type Rules struct {
 	IsByzantium, IsConstantinople, IsPetersburg, IsIstanbul bool
 	IsBerlin                                                bool
 	IsIshikari                                              bool
-	IsCVE_2021_39137BlockPassed                             bool
+	IsCVE_2021_39137BlockPassed                             bool // strictly after the CVE_2021_39137Block, see IsCVE202139137
 }
 
 // Rules ensures c's ChainID is not nil.
 func (c *ChainConfig) Rules(num *big.Int) Rules {
+	rules := c.RulesShared(num)
+	rules.ChainID = new(big.Int).Set(rules.ChainID)
+	return rules
+}
+
+// nilConfigWarning ensures the nil config warning is only logged once.
+var nilConfigWarning sync.Once
+
+// RulesShared is like Rules, but avoids allocating a copy of the chain ID for
+// hot paths. The returned ChainID is shared with the config and must not be
+// modified by the caller. Configs without a chain ID get a fresh zero one.
+func (c *ChainConfig) RulesShared(num *big.Int) Rules {
+	if c == nil {
+		nilConfigWarning.Do(func() {
+			log.Warn("Fork rules queried on nil chain config, assuming no forks")
+		})
+		return Rules{ChainID: new(big.Int)}
+	}
 	chainID := c.ChainID
 	if chainID == nil {
 		chainID = new(big.Int)
 	}
 	return Rules{
-		ChainID:                     new(big.Int).Set(chainID),
+		ChainID:                     chainID,
 		IsHomestead:                 c.IsHomestead(num),
 		IsEIP150:                    c.IsEIP150(num),
 		IsEIP155:                    c.IsEIP155(num),
@@ -678,6 +3101,113 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 		IsIstanbul:                  c.IsIstanbul(num),
 		IsBerlin:                    c.IsBerlin(num),
 		IsIshikari:                  c.IsKCCIshikari(num),
-		IsCVE_2021_39137BlockPassed: c.CVE_2021_39137Block == nil || c.CVE_2021_39137Block.Cmp(num) < 0,
+		IsCVE_2021_39137BlockPassed: c.IsCVE202139137(num),
+	}
+}
+
+// Precompiled contract address sets by era, mirroring the precompiles of the
+// EVM in core/vm. They are shared by all Rules and must not be modified.
+var (
+	precompilesHomestead = precompileSet(1, 4)
+	precompilesByzantium = precompileSet(1, 8)
+	precompilesIstanbul  = precompileSet(1, 9) // blake2f
+)
+
+// precompileSet returns the set of precompile addresses from first to last.
+func precompileSet(first, last byte) map[common.Address]bool {
+	set := make(map[common.Address]bool)
+	for i := first; i <= last; i++ {
+		set[common.BytesToAddress([]byte{i})] = true
+	}
+	return set
+}
+
+// PrecompileSet returns the addresses of the precompiled contracts available
+// under the rules. The set is shared and must not be modified.
+func (r Rules) PrecompileSet() map[common.Address]bool {
+	switch {
+	case r.IsIstanbul, r.IsBerlin:
+		return precompilesIstanbul
+	case r.IsByzantium:
+		return precompilesByzantium
+	default:
+		return precompilesHomestead
+	}
+}
+
+// RequiresChainID returns whether transactions must be replay protected by the
+// chain ID under the rules, i.e. whether EIP155 is active.
+func (r Rules) RequiresChainID() bool {
+	return r.IsEIP155
+}
+
+// SupportsTxType returns whether transactions of the given EIP-2718 type are
+// permitted under the rules. The type numbers mirror the ones in core/types:
+// legacy transactions (0) are always allowed and access list transactions (1)
+// from Berlin on. Dynamic fee transactions (2) aren't implemented by this
+// client, so they are rejected along with any unknown type regardless of the
+// London fork.
+func (r Rules) SupportsTxType(txType uint8) bool {
+	switch txType {
+	case 0:
+		return true
+	case 1:
+		return r.IsBerlin
+	default:
+		return false
+	}
+}
+
+// HeadRules is an immutable snapshot of the rules in effect at a given block,
+// bundling them with the block number so both can be passed around together.
+type HeadRules struct {
+	number *big.Int
+	rules  Rules
+}
+
+// HeadRulesAt returns the snapshot of the rules in effect at block num. Like
+// Rules, a nil num is accepted and treated as the genesis block.
+func (c *ChainConfig) HeadRulesAt(num *big.Int) HeadRules {
+	head := HeadRules{rules: c.Rules(num)}
+	if num != nil {
+		head.number = new(big.Int).Set(num)
+	}
+	return head
+}
+
+// Number returns a copy of the block number of the snapshot, or nil if the
+// snapshot was taken at a nil number.
+func (h HeadRules) Number() *big.Int {
+	if h.number == nil {
+		return nil
 	}
+	return new(big.Int).Set(h.number)
+}
+
+// ChainID returns a copy of the chain ID of the snapshot.
+func (h HeadRules) ChainID() *big.Int {
+	return new(big.Int).Set(h.rules.ChainID)
+}
+
+// Rules returns the rules of the snapshot. The chain ID is copied, so that the
+// snapshot can't be modified through it.
+func (h HeadRules) Rules() Rules {
+	rules := h.rules
+	rules.ChainID = h.ChainID()
+	return rules
+}
+
+// IsEIP155 returns whether replay protection is active at the snapshot block.
+func (h HeadRules) IsEIP155() bool {
+	return h.rules.IsEIP155
+}
+
+// IsBerlin returns whether Berlin is active at the snapshot block.
+func (h HeadRules) IsBerlin() bool {
+	return h.rules.IsBerlin
+}
+
+// IsIshikari returns whether Ishikari is active at the snapshot block.
+func (h HeadRules) IsIshikari() bool {
+	return h.rules.IsIshikari
 }
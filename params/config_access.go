@@ -0,0 +1,45 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/params/internal/paramsaccess"
+)
+
+// Install the hooks giving params/paramstest access to the fork registry.
+func init() {
+	paramsaccess.ForkBlock = func(config interface{}, name string) **big.Int {
+		fork, ok := config.(*ChainConfig).fork(name)
+		if !ok {
+			return nil
+		}
+		return fork.block
+	}
+	paramsaccess.OrderedForks = func() []string {
+		var names []string
+		for _, fork := range new(ChainConfig).forkOrder() {
+			names = append(names, strings.TrimSuffix(fork.name, "Block"))
+		}
+		return names
+	}
+	paramsaccess.IsUnorderedFork = func(name string) bool {
+		return unorderedForks[name]
+	}
+}
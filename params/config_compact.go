@@ -0,0 +1,211 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// CompactUnset is the fork block of a CompactConfig fork which isn't scheduled.
+const CompactUnset = math.MaxUint64
+
+// CompactConfig is the fork schedule of a ChainConfig without any big integers,
+// for header verifiers running in constrained environments. Unscheduled forks
+// (and a missing chain ID) are denoted by CompactUnset.
+type CompactConfig struct {
+	ChainID uint64
+
+	HomesteadBlock      uint64
+	DAOForkBlock        uint64
+	DAOForkSupport      bool
+	EIP150Block         uint64
+	EIP155Block         uint64
+	EIP158Block         uint64
+	ByzantiumBlock      uint64
+	ConstantinopleBlock uint64
+	PetersburgBlock     uint64
+	IstanbulBlock       uint64
+	MuirGlacierBlock    uint64
+	BerlinBlock         uint64
+	CVE_2021_39137Block uint64
+
+	IshikariBlock         uint64
+	IshikariPatch001Block uint64
+	IshikariPatch002Block uint64
+
+	LondonBlock        uint64
+	MergeNetsplitBlock uint64
+
+	YoloV3Block uint64
+	EWASMBlock  uint64
+}
+
+// blocks returns the fork block fields of the compact config, in the order of
+// the ChainConfig forks.
+func (cc *CompactConfig) blocks() []*uint64 {
+	return []*uint64{
+		&cc.HomesteadBlock,
+		&cc.DAOForkBlock,
+		&cc.EIP150Block,
+		&cc.EIP155Block,
+		&cc.EIP158Block,
+		&cc.ByzantiumBlock,
+		&cc.ConstantinopleBlock,
+		&cc.PetersburgBlock,
+		&cc.IstanbulBlock,
+		&cc.MuirGlacierBlock,
+		&cc.BerlinBlock,
+		&cc.CVE_2021_39137Block,
+		&cc.IshikariBlock,
+		&cc.IshikariPatch001Block,
+		&cc.IshikariPatch002Block,
+		&cc.LondonBlock,
+		&cc.MergeNetsplitBlock,
+		&cc.YoloV3Block,
+		&cc.EWASMBlock,
+	}
+}
+
+// ToCompact converts the fork schedule of the config into its compact form. It
+// fails if the chain ID or any fork block doesn't fit into an uint64 (or would
+// collide with CompactUnset). The engine and the other parameters are dropped.
+func (c *ChainConfig) ToCompact() (CompactConfig, error) {
+	cc := CompactConfig{ChainID: CompactUnset, DAOForkSupport: c.DAOForkSupport}
+	if c.ChainID != nil {
+		if !c.ChainID.IsUint64() || c.ChainID.Uint64() == CompactUnset {
+			return CompactConfig{}, fmt.Errorf("chain ID %v too large for compact config", c.ChainID)
+		}
+		cc.ChainID = c.ChainID.Uint64()
+	}
+	blocks := cc.blocks()
+	for i, fork := range c.forks() {
+		*blocks[i] = CompactUnset
+		if *fork.block == nil {
+			continue
+		}
+		if !(*fork.block).IsUint64() || (*fork.block).Uint64() == CompactUnset {
+			return CompactConfig{}, fmt.Errorf("%s fork block %v too large for compact config", fork.name, *fork.block)
+		}
+		*blocks[i] = (*fork.block).Uint64()
+	}
+	return cc, nil
+}
+
+// FromCompact converts the compact fork schedule back into a chain config.
+func (cc CompactConfig) FromCompact() *ChainConfig {
+	c := &ChainConfig{DAOForkSupport: cc.DAOForkSupport}
+	if cc.ChainID != CompactUnset {
+		c.ChainID = new(big.Int).SetUint64(cc.ChainID)
+	}
+	blocks := cc.blocks()
+	for i, fork := range c.forks() {
+		if *blocks[i] != CompactUnset {
+			*fork.block = new(big.Int).SetUint64(*blocks[i])
+		}
+	}
+	return c
+}
+
+// isCompactForked returns whether a fork scheduled at block s is active at num.
+func isCompactForked(s, num uint64) bool {
+	return s != CompactUnset && s <= num
+}
+
+// IsHomestead returns whether num is either equal to the homestead block or greater.
+func (cc *CompactConfig) IsHomestead(num uint64) bool {
+	return isCompactForked(cc.HomesteadBlock, num)
+}
+
+// IsDAOFork returns whether num is either equal to the DAO fork block or greater.
+func (cc *CompactConfig) IsDAOFork(num uint64) bool {
+	return isCompactForked(cc.DAOForkBlock, num)
+}
+
+// IsEIP150 returns whether num is either equal to the EIP150 fork block or greater.
+func (cc *CompactConfig) IsEIP150(num uint64) bool {
+	return isCompactForked(cc.EIP150Block, num)
+}
+
+// IsEIP155 returns whether num is either equal to the EIP155 fork block or greater.
+func (cc *CompactConfig) IsEIP155(num uint64) bool {
+	return isCompactForked(cc.EIP155Block, num)
+}
+
+// IsEIP158 returns whether num is either equal to the EIP158 fork block or greater.
+func (cc *CompactConfig) IsEIP158(num uint64) bool {
+	return isCompactForked(cc.EIP158Block, num)
+}
+
+// IsByzantium returns whether num is either equal to the Byzantium fork block or greater.
+func (cc *CompactConfig) IsByzantium(num uint64) bool {
+	return isCompactForked(cc.ByzantiumBlock, num)
+}
+
+// IsConstantinople returns whether num is either equal to the Constantinople fork block or greater.
+func (cc *CompactConfig) IsConstantinople(num uint64) bool {
+	return isCompactForked(cc.ConstantinopleBlock, num)
+}
+
+// IsPetersburg returns whether num is either
+// - equal to or greater than the PetersburgBlock fork block,
+// - OR is unset, and Constantinople is active
+func (cc *CompactConfig) IsPetersburg(num uint64) bool {
+	return isCompactForked(cc.PetersburgBlock, num) || cc.PetersburgBlock == CompactUnset && isCompactForked(cc.ConstantinopleBlock, num)
+}
+
+// IsIstanbul returns whether num is either equal to the Istanbul fork block or greater.
+func (cc *CompactConfig) IsIstanbul(num uint64) bool {
+	return isCompactForked(cc.IstanbulBlock, num)
+}
+
+// IsMuirGlacier returns whether num is either equal to the Muir Glacier (EIP-2384) fork block or greater.
+func (cc *CompactConfig) IsMuirGlacier(num uint64) bool {
+	return isCompactForked(cc.MuirGlacierBlock, num)
+}
+
+// IsBerlin returns whether num is either equal to the Berlin fork block or greater.
+func (cc *CompactConfig) IsBerlin(num uint64) bool {
+	return isCompactForked(cc.BerlinBlock, num) || isCompactForked(cc.YoloV3Block, num)
+}
+
+// IsLondon returns whether num is either equal to the London fork block or
+// greater, following Berlin if unset, see ChainConfig.IsLondon.
+func (cc *CompactConfig) IsLondon(num uint64) bool {
+	if cc.LondonBlock != CompactUnset {
+		return isCompactForked(cc.LondonBlock, num)
+	}
+	return cc.IsBerlin(num)
+}
+
+// IsCVE202139137 returns whether num is past the CVE_2021_39137Block "fake"
+// fork. The boundary is exclusive and an unset fork means the fix always
+// applies, see ChainConfig.IsCVE202139137.
+func (cc *CompactConfig) IsCVE202139137(num uint64) bool {
+	return cc.CVE_2021_39137Block == CompactUnset || cc.CVE_2021_39137Block < num
+}
+
+// IsKCCIshikari returns whether num is either equal to the Ishikari fork block or greater.
+func (cc *CompactConfig) IsKCCIshikari(num uint64) bool {
+	return isCompactForked(cc.IshikariBlock, num)
+}
+
+// IsEWASM returns whether num represents a block number after the EWASM fork
+func (cc *CompactConfig) IsEWASM(num uint64) bool {
+	return isCompactForked(cc.EWASMBlock, num)
+}
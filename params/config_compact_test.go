@@ -0,0 +1,80 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestCompactConfigRoundTrip(t *testing.T) {
+	for _, config := range []*ChainConfig{MainnetChainConfig, TestnetChainConfig, AllEthashProtocolChanges, {}} {
+		cc, err := config.ToCompact()
+		if err != nil {
+			t.Fatalf("failed to compact config: %v", err)
+		}
+		dec := cc.FromCompact()
+		if !configNumEqual(dec.ChainID, config.ChainID) || dec.DAOForkSupport != config.DAOForkSupport {
+			t.Errorf("chain parameters mismatch: have (%v, %v), want (%v, %v)", dec.ChainID, dec.DAOForkSupport, config.ChainID, config.DAOForkSupport)
+		}
+		theirs := config.forks()
+		for i, fork := range dec.forks() {
+			if !configNumEqual(*fork.block, *theirs[i].block) {
+				t.Errorf("%s fork mismatch: have %v, want %v", fork.name, *fork.block, *theirs[i].block)
+			}
+		}
+	}
+}
+
+func TestCompactConfigPredicates(t *testing.T) {
+	cc, err := MainnetChainConfig.ToCompact()
+	if err != nil {
+		t.Fatalf("failed to compact config: %v", err)
+	}
+	for _, num := range []uint64{0, 2509228, 2509229, 11171298, 11171299} {
+		bnum := new(big.Int).SetUint64(num)
+		if have, want := cc.IsKCCIshikari(num), MainnetChainConfig.IsKCCIshikari(bnum); have != want {
+			t.Errorf("ishikari mismatch at %d: have %v, want %v", num, have, want)
+		}
+		if have, want := cc.IsCVE202139137(num), MainnetChainConfig.IsCVE202139137(bnum); have != want {
+			t.Errorf("cve_2021_39137 mismatch at %d: have %v, want %v", num, have, want)
+		}
+		if have, want := cc.IsPetersburg(num), MainnetChainConfig.IsPetersburg(bnum); have != want {
+			t.Errorf("petersburg mismatch at %d: have %v, want %v", num, have, want)
+		}
+		if have, want := cc.IsDAOFork(num), MainnetChainConfig.IsDAOFork(bnum); have != want {
+			t.Errorf("dao mismatch at %d: have %v, want %v", num, have, want)
+		}
+	}
+}
+
+func TestCompactConfigOverflow(t *testing.T) {
+	config := &ChainConfig{IshikariBlock: new(big.Int).Lsh(common.Big1, 64)}
+	if _, err := config.ToCompact(); err == nil {
+		t.Errorf("oversized fork block compacted")
+	}
+	config = &ChainConfig{IshikariBlock: new(big.Int).SetUint64(CompactUnset)}
+	if _, err := config.ToCompact(); err == nil {
+		t.Errorf("fork block colliding with the unset marker compacted")
+	}
+	config = &ChainConfig{ChainID: new(big.Int).Lsh(common.Big1, 64)}
+	if _, err := config.ToCompact(); err == nil {
+		t.Errorf("oversized chain ID compacted")
+	}
+}
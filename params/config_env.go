@@ -0,0 +1,87 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ApplyEnvOverrides overrides fork blocks of the config from the environment,
+// for devnets testing fork transitions. Every fork is looked up in a variable
+// named after its JSON key in upper snake case behind the prefix, e.g.
+// <PREFIX>_ISHIKARI_BLOCK or <PREFIX>_ISHIKARI_PATCH001_BLOCK, holding a decimal
+// or 0x-prefixed hex block number. The JSON keys of the overridden fields are
+// returned. Malformed values or a resulting invalid fork ordering fail the call
+// without modifying the config.
+func (c *ChainConfig) ApplyEnvOverrides(prefix string) ([]string, error) {
+	var (
+		cpy        = c.Clone()
+		overridden []string
+	)
+	for _, fork := range cpy.forks() {
+		field := fork.name + "Block"
+		name := prefix + "_" + envName(field)
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			continue
+		}
+		block, ok := parseEnvBlock(value)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s value %q", name, value)
+		}
+		*fork.block = block
+		overridden = append(overridden, field)
+	}
+	if err := cpy.CheckConfigForkOrder(); err != nil {
+		return nil, err
+	}
+	*c = *cpy
+	return overridden, nil
+}
+
+// parseEnvBlock parses a block number in plain decimal or 0x-prefixed hex form.
+// Decimals with leading zeros are rejected, as they would read as octal in many
+// other tools.
+func parseEnvBlock(value string) (*big.Int, bool) {
+	text, base := value, 10
+	if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X") {
+		text, base = text[2:], 16
+	} else if len(text) > 1 && text[0] == '0' {
+		return nil, false
+	}
+	if text == "" || text[0] == '-' || text[0] == '+' {
+		return nil, false
+	}
+	return new(big.Int).SetString(text, base)
+}
+
+// envName converts a camel case JSON key into an upper snake case environment
+// variable name, e.g. ishikariPatch001Block to ISHIKARI_PATCH001_BLOCK.
+func envName(key string) string {
+	var name strings.Builder
+	for i, r := range key {
+		if i > 0 && unicode.IsUpper(r) {
+			name.WriteByte('_')
+		}
+		name.WriteRune(unicode.ToUpper(r))
+	}
+	return name.String()
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"math/big"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestApplyEnvOverrides(t *testing.T) {
+	os.Setenv("DEVNET_ISHIKARI_PATCH002_BLOCK", "12500000")
+	defer os.Unsetenv("DEVNET_ISHIKARI_PATCH002_BLOCK")
+
+	config := TestnetChainConfig.Clone()
+	fields, err := config.ApplyEnvOverrides("DEVNET")
+	if err != nil {
+		t.Fatalf("failed to apply overrides: %v", err)
+	}
+	if want := []string{"ishikariPatch002Block"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("overridden fields mismatch: have %v, want %v", fields, want)
+	}
+	if config.IshikariPatch002Block.Cmp(big.NewInt(12500000)) != 0 {
+		t.Errorf("override not applied: have %v", config.IshikariPatch002Block)
+	}
+	// Malformed values and broken fork orderings must leave the config alone
+	os.Setenv("DEVNET_ISHIKARI_PATCH002_BLOCK", "soon")
+	if _, err := config.ApplyEnvOverrides("DEVNET"); err == nil {
+		t.Errorf("malformed block accepted")
+	}
+	for _, value := range []string{"010", "0b1", "0o7", "1_000", "-1", "+1", "0x"} {
+		os.Setenv("DEVNET_ISHIKARI_PATCH002_BLOCK", value)
+		if _, err := config.ApplyEnvOverrides("DEVNET"); err == nil {
+			t.Errorf("malformed block %q accepted", value)
+		}
+	}
+	os.Setenv("DEVNET_ISHIKARI_PATCH002_BLOCK", "0x1")
+	if _, err := config.ApplyEnvOverrides("DEVNET"); err == nil {
+		t.Errorf("invalid fork ordering accepted")
+	}
+	if config.IshikariPatch002Block.Cmp(big.NewInt(12500000)) != 0 {
+		t.Errorf("config modified by failed override: have %v", config.IshikariPatch002Block)
+	}
+	// Hex blocks must be accepted too
+	os.Setenv("DEVNET_ISHIKARI_PATCH002_BLOCK", "0xBEBC21")
+	if _, err := config.ApplyEnvOverrides("DEVNET"); err != nil || config.IshikariPatch002Block.Cmp(big.NewInt(12500001)) != 0 {
+		t.Errorf("hex block not applied: have %v, %v", config.IshikariPatch002Block, err)
+	}
+}
@@ -0,0 +1,455 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ConfigSchemaVersion is the version of the ChainConfig JSON layout written by
+// this binary. Bump it whenever fields are added, so that older binaries can
+// detect configs containing fields they silently drop.
+const ConfigSchemaVersion uint64 = 1
+
+// jsonBlock is a fork block number. It is encoded as a plain JSON number, the
+// format every version of the config was persisted in, or as a decimal string
+// if quoted, so that downstream parsers of exported genesis files never coerce
+// it into a float. Decoding accepts both forms and 0x-prefixed hex strings
+// emitted by some external tools.
+type jsonBlock struct {
+	num    *big.Int
+	quoted bool
+}
+
+// newJSONBlock wraps a fork block for encoding, retaining nil.
+func newJSONBlock(num *big.Int, quoted bool) *jsonBlock {
+	if num == nil {
+		return nil
+	}
+	return &jsonBlock{num: num, quoted: quoted}
+}
+
+// block returns the decoded fork block, retaining nil.
+func (b *jsonBlock) block() *big.Int {
+	if b == nil {
+		return nil
+	}
+	return b.num
+}
+
+// MarshalJSON implements json.Marshaler.
+func (b *jsonBlock) MarshalJSON() ([]byte, error) {
+	if b.quoted {
+		return []byte(strconv.Quote(b.num.String())), nil
+	}
+	return []byte(b.num.String()), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *jsonBlock) UnmarshalJSON(input []byte) error {
+	text, base := string(input), 10
+	if len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"' {
+		text = text[1 : len(text)-1]
+		if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X") {
+			text, base = text[2:], 16
+		}
+	}
+	if text == "" || text[0] == '-' || text[0] == '+' {
+		return fmt.Errorf("invalid fork block number %s", input)
+	}
+	if b.num == nil {
+		b.num = new(big.Int)
+	}
+	if _, ok := b.num.SetString(text, base); !ok {
+		return fmt.Errorf("invalid fork block number %s", input)
+	}
+	return nil
+}
+
+// chainConfigJSON is the JSON layout of ChainConfig. The field order of this
+// struct is the order in which fields are serialized, keep it stable and only
+// ever append new fields next to their semantic siblings.
+type chainConfigJSON struct {
+	ChainID       *big.Int `json:"chainId"`
+	ConfigVersion uint64   `json:"configVersion,omitempty"`
+
+	HomesteadBlock *jsonBlock `json:"homesteadBlock,omitempty"`
+
+	DAOForkBlock   *jsonBlock `json:"daoForkBlock,omitempty"`
+	DAOForkSupport bool       `json:"daoForkSupport,omitempty"`
+
+	EIP150Block *jsonBlock  `json:"eip150Block,omitempty"`
+	EIP150Hash  common.Hash `json:"eip150Hash,omitempty"`
+
+	EIP155Block *jsonBlock `json:"eip155Block,omitempty"`
+	EIP158Block *jsonBlock `json:"eip158Block,omitempty"`
+
+	ByzantiumBlock      *jsonBlock `json:"byzantiumBlock,omitempty"`
+	ConstantinopleBlock *jsonBlock `json:"constantinopleBlock,omitempty"`
+	PetersburgBlock     *jsonBlock `json:"petersburgBlock,omitempty"`
+	IstanbulBlock       *jsonBlock `json:"istanbulBlock,omitempty"`
+	MuirGlacierBlock    *jsonBlock `json:"muirGlacierBlock,omitempty"`
+	BerlinBlock         *jsonBlock `json:"berlinBlock,omitempty"`
+
+	CVE_2021_39137Block *jsonBlock `json:"cve_2021_39137Block,omitempty"`
+
+	IshikariBlock         *jsonBlock `json:"ishikariBlock,omitempty"`
+	IshikariPatch001Block *jsonBlock `json:"ishikariPatch001Block,omitempty"`
+	IshikariPatch002Block *jsonBlock `json:"ishikariPatch002Block,omitempty"`
+
+	LondonBlock *jsonBlock `json:"londonBlock,omitempty"`
+
+	TerminalTotalDifficulty *big.Int   `json:"terminalTotalDifficulty,omitempty"`
+	MergeNetsplitBlock      *jsonBlock `json:"mergeNetsplitBlock,omitempty"`
+
+	YoloV3Block *jsonBlock `json:"yoloV3Block,omitempty"`
+	EWASMBlock  *jsonBlock `json:"ewasmBlock,omitempty"`
+
+	BaseFeeChangeDenominator *uint64 `json:"baseFeeChangeDenominator,omitempty"`
+	ElasticityMultiplier     *uint64 `json:"elasticityMultiplier,omitempty"`
+
+	InitialGasLimit *uint64 `json:"initialGasLimit,omitempty"`
+	GasTarget       *uint64 `json:"gasTarget,omitempty"`
+
+	MinClientVersions map[string]string   `json:"minClientVersions,omitempty"`
+	DisabledOpcodes   map[string][]string `json:"disabledOpcodes,omitempty"`
+
+	FeeExemptAddresses []common.Address `json:"feeExemptAddresses,omitempty"`
+
+	Ethash *EthashConfig `json:"ethash,omitempty"`
+	Clique *CliqueConfig `json:"clique,omitempty"`
+	POSA   *POSAConfig   `json:"posa,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. The fields are emitted in the order
+// of chainConfigJSON and fork blocks are written as JSON numbers, keeping the
+// format of configs persisted in the database and served over RPC readable by
+// older versions. Configs without a schema version are stamped with the current
+// one.
+func (c ChainConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.encodeJSON(false))
+}
+
+// ExportJSON returns the JSON encoding of the config for genesis files committed
+// to version control: the fields are emitted like MarshalJSON does, but with
+// two-space indentation and with the fork blocks written as decimal strings, so
+// that downstream parsers never coerce them into floats. UnmarshalJSON decodes
+// both forms.
+func (c *ChainConfig) ExportJSON() ([]byte, error) {
+	return json.MarshalIndent(c.encodeJSON(true), "", "  ")
+}
+
+// encodeJSON converts the config into its JSON layout, with the fork blocks
+// encoded as decimal strings if quoted.
+func (c *ChainConfig) encodeJSON(quoted bool) *chainConfigJSON {
+	enc := &chainConfigJSON{
+		ChainID:                  c.ChainID,
+		ConfigVersion:            c.ConfigVersion,
+		HomesteadBlock:           newJSONBlock(c.HomesteadBlock, quoted),
+		DAOForkBlock:             newJSONBlock(c.DAOForkBlock, quoted),
+		DAOForkSupport:           c.DAOForkSupport,
+		EIP150Block:              newJSONBlock(c.EIP150Block, quoted),
+		EIP150Hash:               c.EIP150Hash,
+		EIP155Block:              newJSONBlock(c.EIP155Block, quoted),
+		EIP158Block:              newJSONBlock(c.EIP158Block, quoted),
+		ByzantiumBlock:           newJSONBlock(c.ByzantiumBlock, quoted),
+		ConstantinopleBlock:      newJSONBlock(c.ConstantinopleBlock, quoted),
+		PetersburgBlock:          newJSONBlock(c.PetersburgBlock, quoted),
+		IstanbulBlock:            newJSONBlock(c.IstanbulBlock, quoted),
+		MuirGlacierBlock:         newJSONBlock(c.MuirGlacierBlock, quoted),
+		BerlinBlock:              newJSONBlock(c.BerlinBlock, quoted),
+		CVE_2021_39137Block:      newJSONBlock(c.CVE_2021_39137Block, quoted),
+		IshikariBlock:            newJSONBlock(c.IshikariBlock, quoted),
+		IshikariPatch001Block:    newJSONBlock(c.IshikariPatch001Block, quoted),
+		IshikariPatch002Block:    newJSONBlock(c.IshikariPatch002Block, quoted),
+		LondonBlock:              newJSONBlock(c.LondonBlock, quoted),
+		TerminalTotalDifficulty:  c.TerminalTotalDifficulty,
+		MergeNetsplitBlock:       newJSONBlock(c.MergeNetsplitBlock, quoted),
+		YoloV3Block:              newJSONBlock(c.YoloV3Block, quoted),
+		EWASMBlock:               newJSONBlock(c.EWASMBlock, quoted),
+		BaseFeeChangeDenominator: c.BaseFeeChangeDenominator,
+		ElasticityMultiplier:     c.ElasticityMultiplier,
+		InitialGasLimit:          c.InitialGasLimit,
+		GasTarget:                c.GasTarget,
+		MinClientVersions:        c.MinClientVersions,
+		DisabledOpcodes:          c.DisabledOpcodes,
+		FeeExemptAddresses:       c.FeeExemptAddresses,
+		Ethash:                   c.Ethash,
+		Clique:                   c.Clique,
+		POSA:                     c.POSA,
+	}
+	if enc.ConfigVersion == 0 {
+		enc.ConfigVersion = ConfigSchemaVersion
+	}
+	return enc
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *ChainConfig) UnmarshalJSON(input []byte) error {
+	var dec chainConfigJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	*c = ChainConfig{
+		ChainID:                  dec.ChainID,
+		ConfigVersion:            dec.ConfigVersion,
+		HomesteadBlock:           dec.HomesteadBlock.block(),
+		DAOForkBlock:             dec.DAOForkBlock.block(),
+		DAOForkSupport:           dec.DAOForkSupport,
+		EIP150Block:              dec.EIP150Block.block(),
+		EIP150Hash:               dec.EIP150Hash,
+		EIP155Block:              dec.EIP155Block.block(),
+		EIP158Block:              dec.EIP158Block.block(),
+		ByzantiumBlock:           dec.ByzantiumBlock.block(),
+		ConstantinopleBlock:      dec.ConstantinopleBlock.block(),
+		PetersburgBlock:          dec.PetersburgBlock.block(),
+		IstanbulBlock:            dec.IstanbulBlock.block(),
+		MuirGlacierBlock:         dec.MuirGlacierBlock.block(),
+		BerlinBlock:              dec.BerlinBlock.block(),
+		CVE_2021_39137Block:      dec.CVE_2021_39137Block.block(),
+		IshikariBlock:            dec.IshikariBlock.block(),
+		IshikariPatch001Block:    dec.IshikariPatch001Block.block(),
+		IshikariPatch002Block:    dec.IshikariPatch002Block.block(),
+		LondonBlock:              dec.LondonBlock.block(),
+		TerminalTotalDifficulty:  dec.TerminalTotalDifficulty,
+		MergeNetsplitBlock:       dec.MergeNetsplitBlock.block(),
+		YoloV3Block:              dec.YoloV3Block.block(),
+		EWASMBlock:               dec.EWASMBlock.block(),
+		BaseFeeChangeDenominator: dec.BaseFeeChangeDenominator,
+		ElasticityMultiplier:     dec.ElasticityMultiplier,
+		InitialGasLimit:          dec.InitialGasLimit,
+		GasTarget:                dec.GasTarget,
+		MinClientVersions:        dec.MinClientVersions,
+		DisabledOpcodes:          dec.DisabledOpcodes,
+		FeeExemptAddresses:       dec.FeeExemptAddresses,
+		Ethash:                   dec.Ethash,
+		Clique:                   dec.Clique,
+		POSA:                     dec.POSA,
+	}
+	if len(c.FeeExemptAddresses) > 0 {
+		c.feeExempt = make(map[common.Address]struct{}, len(c.FeeExemptAddresses))
+		for _, addr := range c.FeeExemptAddresses {
+			c.feeExempt[addr] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// NormalizeAddresses returns the JSON encoding of the config like ExportJSON,
+// but with the POSA addresses written in EIP-55 checksum form, for lint-clean
+// hand-maintained genesis files. Addresses are plain bytes in memory, so the
+// casing used in a parsed file is never retained; MarshalJSON always writes them
+// in lowercase.
+func (c *ChainConfig) NormalizeAddresses() ([]byte, error) {
+	// The POSA config is the last field of the layout, so shadowing it keeps
+	// the field order of ExportJSON intact.
+	enc := struct {
+		*chainConfigJSON
+		POSA *checksumPOSAConfig `json:"posa,omitempty"`
+	}{c.encodeJSON(true), newChecksumPOSAConfig(c.POSA)}
+
+	return json.MarshalIndent(enc, "", "  ")
+}
+
+// checksumAddress is an address encoded in EIP-55 checksum form.
+type checksumAddress common.Address
+
+// MarshalText implements encoding.TextMarshaler.
+func (a checksumAddress) MarshalText() ([]byte, error) {
+	return []byte(common.Address(a).Hex()), nil
+}
+
+// checksumPOSAConfig is the JSON layout of POSAConfig with all the addresses in
+// checksum form. It must mirror POSAConfig field by field.
+type checksumPOSAConfig struct {
+	Period                    uint64              `json:"period"`
+	Epoch                     uint64              `json:"epoch"`
+	CheckpointInterval        uint64              `json:"checkpointInterval,omitempty"`
+	IshikariInitialValidators []checksumAddress   `json:"ishikariInitialValidators"`
+	IshikariInitialManagers   []checksumAddress   `json:"ishikariInitialManagers"`
+	ManagersOptional          bool                `json:"managersOptional,omitempty"`
+	IshikariAdminMultiSig     checksumAddress     `json:"ishikariAdminAddress"`
+	ValidatorContractAddress  *checksumAddress    `json:"validatorContractAddress,omitempty"`
+	PunishContractAddress     *checksumAddress    `json:"punishContractAddress,omitempty"`
+	JailThreshold             uint64              `json:"jailThreshold,omitempty"`
+	MaxValidators             uint64              `json:"maxValidators,omitempty"`
+	MaxValidatorsSchedule     []MaxValidatorsStep `json:"maxValidatorsSchedule,omitempty"`
+}
+
+// newChecksumPOSAConfig converts a POSA config into its checksummed layout,
+// retaining nil.
+func newChecksumPOSAConfig(c *POSAConfig) *checksumPOSAConfig {
+	if c == nil {
+		return nil
+	}
+	return &checksumPOSAConfig{
+		Period:                    c.Period,
+		Epoch:                     c.Epoch,
+		CheckpointInterval:        c.CheckpointInterval,
+		IshikariInitialValidators: checksumAddresses(c.IshikariInitialValidators),
+		IshikariInitialManagers:   checksumAddresses(c.IshikariInitialManagers),
+		ManagersOptional:          c.ManagersOptional,
+		IshikariAdminMultiSig:     checksumAddress(c.IshikariAdminMultiSig),
+		ValidatorContractAddress:  (*checksumAddress)(c.ValidatorContractAddress),
+		PunishContractAddress:     (*checksumAddress)(c.PunishContractAddress),
+		JailThreshold:             c.JailThreshold,
+		MaxValidators:             c.MaxValidators,
+		MaxValidatorsSchedule:     c.MaxValidatorsSchedule,
+	}
+}
+
+// checksumAddresses converts a list of addresses for checksummed encoding,
+// retaining nil.
+func checksumAddresses(addrs []common.Address) []checksumAddress {
+	if addrs == nil {
+		return nil
+	}
+	cpy := make([]checksumAddress, len(addrs))
+	for i, addr := range addrs {
+		cpy[i] = checksumAddress(addr)
+	}
+	return cpy
+}
+
+// AsMap returns the config in the shape of its exported JSON encoding, for
+// templating genesis files: keys are the JSON field names, fork blocks are
+// decimal strings, other numbers are json.Numbers and the engine configs are
+// nested maps.
+func (c *ChainConfig) AsMap() map[string]interface{} {
+	blob, err := c.ExportJSON()
+	if err != nil {
+		panic(fmt.Sprintf("failed to encode chain config: %v", err))
+	}
+	fields, err := decodeJSONFields(blob)
+	if err != nil {
+		panic(fmt.Sprintf("failed to decode chain config: %v", err))
+	}
+	return fields
+}
+
+// decodeJSONFields decodes a JSON object into a map, keeping the numbers as
+// json.Numbers to retain their exact textual form.
+func decodeJSONFields(blob []byte) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(blob))
+	dec.UseNumber()
+
+	var fields map[string]interface{}
+	if err := dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// CanonicalJSON returns the canonical JSON encoding of the config in the spirit
+// of RFC 8785, so that hashes of genesis files are reproducible across tools:
+// object keys are sorted, there's no insignificant whitespace, no HTML escaping,
+// and integers are written in plain decimal. Fork blocks are decimal strings, as
+// in ExportJSON. All the keys being ASCII, sorting them by bytes matches the
+// UTF-16 ordering the RFC mandates.
+func (c *ChainConfig) CanonicalJSON() ([]byte, error) {
+	blob, err := c.ExportJSON()
+	if err != nil {
+		return nil, err
+	}
+	fields, err := decodeJSONFields(blob)
+	if err != nil {
+		return nil, err
+	}
+	// Maps are encoded with sorted keys, json.Numbers verbatim
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(fields); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// ChainConfigJSONSchema returns a JSON Schema (draft-07) describing the JSON
+// layout of ChainConfig, suitable for editor autocompletion of genesis files.
+// The schema only describes the structure and types, it doesn't capture the
+// semantic rules like the fork ordering.
+func ChainConfigJSONSchema() []byte {
+	schema := jsonSchemaOf(reflect.TypeOf(chainConfigJSON{}))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "ChainConfig"
+	schema["required"] = []string{"chainId"}
+
+	blob, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		panic(fmt.Sprintf("failed to encode chain config schema: %v", err))
+	}
+	return blob
+}
+
+// jsonSchemaOf returns the JSON Schema of values of the given type, as encoded
+// by encoding/json.
+func jsonSchemaOf(typ reflect.Type) map[string]interface{} {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ {
+	case reflect.TypeOf(jsonBlock{}):
+		return map[string]interface{}{"type": []string{"string", "integer"}, "pattern": "^([0-9]+|0[xX][0-9a-fA-F]+)$", "minimum": 0}
+	case reflect.TypeOf(big.Int{}):
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.TypeOf(common.Hash{}):
+		return map[string]interface{}{"type": "string", "pattern": "^0x[0-9a-fA-F]{64}$"}
+	case reflect.TypeOf(common.Address{}):
+		return map[string]interface{}{"type": "string", "pattern": "^0x[0-9a-fA-F]{40}$"}
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchemaOf(typ.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchemaOf(typ.Elem())}
+	case reflect.Struct:
+		props := make(map[string]interface{})
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.PkgPath != "" {
+				continue // unexported
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			props[name] = jsonSchemaOf(field.Type)
+		}
+		return map[string]interface{}{"type": "object", "properties": props}
+	}
+	return map[string]interface{}{}
+}
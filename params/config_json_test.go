@@ -0,0 +1,386 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+const testnetConfigGolden = `{
+  "chainId": 322,
+  "configVersion": 1,
+  "homesteadBlock": "0",
+  "daoForkSupport": true,
+  "eip150Block": "0",
+  "eip150Hash": "0x0000000000000000000000000000000000000000000000000000000000000000",
+  "eip155Block": "0",
+  "eip158Block": "0",
+  "byzantiumBlock": "0",
+  "constantinopleBlock": "0",
+  "petersburgBlock": "0",
+  "istanbulBlock": "0",
+  "muirGlacierBlock": "0",
+  "berlinBlock": "0",
+  "cve_2021_39137Block": "0",
+  "ishikariBlock": "11321699",
+  "ishikariPatch001Block": "12153317",
+  "ishikariPatch002Block": "12162886",
+  "posa": {
+    "period": 3,
+    "epoch": 100,
+    "ishikariInitialValidators": [
+      "0x20b9a60c5a2137259ce81e45a1310a754270753b",
+      "0xe40c3ef8dc2dd6d3edecd8ebdc64a6b68f530589",
+      "0xce7878e800408d60e7b55d7d5c56519f329a77fc",
+      "0xbf8144aa88bea302548f51b3d776b8b7e4453449"
+    ],
+    "ishikariInitialManagers": [
+      "0xc6c450c46f71ad568d8bfa16ca597906eb017c71",
+      "0x9e207e1e0bb946d676fa5c86ed95cc997d6a6369",
+      "0x0b3c112e1dc42487302d3d8b5c0a714ec5bebe27",
+      "0x6862f46c4cf0e4ecce9186651637f4c692015a7c"
+    ],
+    "ishikariAdminAddress": "0x22e4a5dffee45ceabd4d7c45814bfc27df3776b4"
+  }
+}`
+
+func TestChainConfigJSONGolden(t *testing.T) {
+	blob, err := TestnetChainConfig.ExportJSON()
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+	if string(blob) != testnetConfigGolden {
+		t.Errorf("serialized config mismatch:\nhave:\n%s\nwant:\n%s", blob, testnetConfigGolden)
+	}
+}
+
+func TestChainConfigJSONRoundTrip(t *testing.T) {
+	for _, config := range []*ChainConfig{MainnetChainConfig, TestnetChainConfig, AllEthashProtocolChanges, AllCliqueProtocolChanges} {
+		blob, err := json.Marshal(config)
+		if err != nil {
+			t.Fatalf("failed to marshal config: %v", err)
+		}
+		dec := new(ChainConfig)
+		if err := json.Unmarshal(blob, dec); err != nil {
+			t.Fatalf("failed to unmarshal config: %v", err)
+		}
+		if !reflect.DeepEqual(dec, config) {
+			t.Errorf("round-trip mismatch:\nhave: %v\nwant: %v", dec, config)
+		}
+	}
+}
+
+func TestChainConfigJSONNumericBlocks(t *testing.T) {
+	var config ChainConfig
+	if err := json.Unmarshal([]byte(`{"chainId": 126, "homesteadBlock": 0, "ishikariBlock": 11171299}`), &config); err != nil {
+		t.Fatalf("failed to unmarshal config: %v", err)
+	}
+	if config.HomesteadBlock == nil || config.HomesteadBlock.Sign() != 0 {
+		t.Errorf("homestead block mismatch: have %v, want 0", config.HomesteadBlock)
+	}
+	if config.IshikariBlock == nil || config.IshikariBlock.Cmp(big.NewInt(11171299)) != 0 {
+		t.Errorf("ishikari block mismatch: have %v, want 11171299", config.IshikariBlock)
+	}
+	if err := json.Unmarshal([]byte(`{"ishikariBlock": "1.5"}`), &config); err == nil {
+		t.Errorf("expected error for malformed fork block")
+	}
+}
+
+func TestChainConfigJSONBaseFeeParams(t *testing.T) {
+	denom, elasticity := uint64(50), uint64(4)
+	config := &ChainConfig{ChainID: big.NewInt(1), ConfigVersion: ConfigSchemaVersion, BaseFeeChangeDenominator: &denom, ElasticityMultiplier: &elasticity}
+
+	blob, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+	dec := new(ChainConfig)
+	if err := json.Unmarshal(blob, dec); err != nil {
+		t.Fatalf("failed to unmarshal config: %v", err)
+	}
+	if !reflect.DeepEqual(dec, config) {
+		t.Errorf("round-trip mismatch: have %v, want %v", dec, config)
+	}
+	if err := json.Unmarshal([]byte(`{"chainId": 1}`), dec); err != nil {
+		t.Fatalf("failed to unmarshal config: %v", err)
+	}
+	if dec.BaseFeeChangeDenominator != nil || dec.ElasticityMultiplier != nil {
+		t.Errorf("absent fee params decoded as set")
+	}
+	if dec.BaseFeeChangeDenom() != BaseFeeChangeDenominator || dec.ElasticityMult() != ElasticityMultiplier {
+		t.Errorf("absent fee params don't fall back to defaults")
+	}
+}
+
+func TestChainConfigJSONSchema(t *testing.T) {
+	var schema struct {
+		Schema     string                            `json:"$schema"`
+		Properties map[string]map[string]interface{} `json:"properties"`
+	}
+	if err := json.Unmarshal(ChainConfigJSONSchema(), &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	if schema.Schema != "http://json-schema.org/draft-07/schema#" {
+		t.Errorf("schema draft mismatch: have %q", schema.Schema)
+	}
+	if _, ok := schema.Properties["ishikariBlock"]; !ok {
+		t.Errorf("ishikariBlock property missing")
+	}
+	posa, ok := schema.Properties["posa"]
+	if !ok {
+		t.Fatalf("posa property missing")
+	}
+	props, _ := posa["properties"].(map[string]interface{})
+	for _, name := range []string{"period", "epoch", "ishikariInitialValidators", "ishikariInitialManagers", "ishikariAdminAddress"} {
+		if _, ok := props[name]; !ok {
+			t.Errorf("posa property %q missing", name)
+		}
+	}
+	// Every serialized field of the mainnet config must be described
+	var mainnet map[string]interface{}
+	blob, _ := json.Marshal(MainnetChainConfig)
+	json.Unmarshal(blob, &mainnet)
+	for name := range mainnet {
+		if _, ok := schema.Properties[name]; !ok {
+			t.Errorf("property %q missing from schema", name)
+		}
+	}
+}
+
+func TestChainConfigJSONHexBlocks(t *testing.T) {
+	var hex, dec ChainConfig
+	if err := json.Unmarshal([]byte(`{"chainId": 126, "berlinBlock": "0x0", "ishikariBlock": "0xaa75e3"}`), &hex); err != nil {
+		t.Fatalf("failed to unmarshal hex config: %v", err)
+	}
+	if err := json.Unmarshal([]byte(`{"chainId": 126, "berlinBlock": 0, "ishikariBlock": 11171299}`), &dec); err != nil {
+		t.Fatalf("failed to unmarshal decimal config: %v", err)
+	}
+	if !configNumEqual(hex.BerlinBlock, dec.BerlinBlock) || !configNumEqual(hex.IshikariBlock, dec.IshikariBlock) {
+		t.Errorf("hex config mismatch: have %v, want %v", &hex, &dec)
+	}
+	// Hex input is still serialized in decimal
+	blob, err := json.Marshal(&hex)
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+	if !strings.Contains(string(blob), `"ishikariBlock":11171299`) {
+		t.Errorf("fork block not serialized in decimal: %s", blob)
+	}
+	for _, block := range []string{`"0x"`, `"0xzz"`, `""`, `"-1"`, `-1`, `"0b101"`, `"1_000"`} {
+		if err := json.Unmarshal([]byte(`{"ishikariBlock": `+block+`}`), &hex); err == nil {
+			t.Errorf("malformed fork block %s accepted", block)
+		}
+	}
+}
+
+// Tests that the wire format of the config stays decodable by older versions,
+// which expect fork blocks as JSON numbers, however the config is encoded.
+func TestChainConfigJSONWireFormat(t *testing.T) {
+	byPointer, err := json.Marshal(MainnetChainConfig)
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+	byValue, err := json.Marshal(*MainnetChainConfig)
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+	if string(byPointer) != string(byValue) {
+		t.Errorf("encoding by value differs:\nhave %s\nwant %s", byValue, byPointer)
+	}
+	var legacy struct {
+		HomesteadBlock *big.Int `json:"homesteadBlock"`
+		IshikariBlock  *big.Int `json:"ishikariBlock"`
+	}
+	if err := json.Unmarshal(byPointer, &legacy); err != nil {
+		t.Fatalf("legacy decoding failed: %v", err)
+	}
+	if legacy.HomesteadBlock.Sign() != 0 || legacy.IshikariBlock.Cmp(MainnetChainConfig.IshikariBlock) != 0 {
+		t.Errorf("legacy decoding mismatch: homestead %v, ishikari %v", legacy.HomesteadBlock, legacy.IshikariBlock)
+	}
+	// The export format quotes the blocks and still decodes to the same config
+	exported, err := MainnetChainConfig.ExportJSON()
+	if err != nil {
+		t.Fatalf("failed to export config: %v", err)
+	}
+	if !strings.Contains(string(exported), `"ishikariBlock": "11171299"`) {
+		t.Errorf("exported fork block not a decimal string:\n%s", exported)
+	}
+	dec := new(ChainConfig)
+	if err := json.Unmarshal(exported, dec); err != nil {
+		t.Fatalf("failed to unmarshal exported config: %v", err)
+	}
+	if !dec.Equal(MainnetChainConfig) {
+		t.Errorf("exported config decodes differently: %v", dec.Diff(MainnetChainConfig))
+	}
+}
+
+func TestChainConfigJSONVersion(t *testing.T) {
+	// Unversioned configs are stamped with the current schema on encoding
+	blob, err := json.Marshal(&ChainConfig{ChainID: big.NewInt(1)})
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+	dec := new(ChainConfig)
+	if err := json.Unmarshal(blob, dec); err != nil {
+		t.Fatalf("failed to unmarshal config: %v", err)
+	}
+	if dec.ConfigVersion != ConfigSchemaVersion {
+		t.Errorf("version mismatch: have %d, want %d", dec.ConfigVersion, ConfigSchemaVersion)
+	}
+	// Configs predating versioning decode as version zero without complaints
+	if err := json.Unmarshal([]byte(`{"chainId": 1}`), dec); err != nil {
+		t.Fatalf("failed to unmarshal config: %v", err)
+	}
+	if dec.ConfigVersion != 0 {
+		t.Errorf("absent version decoded as %d", dec.ConfigVersion)
+	}
+	if report := dec.ValidateWithReport(); len(report.Warnings) != 0 {
+		t.Errorf("unversioned config warned about: %v", report.Warnings)
+	}
+	// Configs from a newer schema are warned about, but accepted
+	if err := json.Unmarshal([]byte(`{"chainId": 1, "configVersion": 1000, "fancyNewBlock": "10"}`), dec); err != nil {
+		t.Fatalf("failed to unmarshal config: %v", err)
+	}
+	report := dec.ValidateWithReport()
+	if len(report.Errors) != 0 {
+		t.Errorf("newer config rejected: %v", report.Errors)
+	}
+	if len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], "configVersion 1000") {
+		t.Errorf("newer config warnings mismatch: %v", report.Warnings)
+	}
+}
+
+func TestNormalizeAddresses(t *testing.T) {
+	var config ChainConfig
+	input := `{"chainId": 1, "posa": {"period": 3, "epoch": 100, "ishikariAdminAddress": "0x22e4a5dffee45ceabd4d7c45814bfc27df3776b4",
+		"ishikariInitialValidators": ["0x20b9a60c5a2137259ce81e45a1310a754270753b"], "ishikariInitialManagers": ["0xC6C450C46F71AD568D8BFA16CA597906EB017C71"],
+		"punishContractAddress": "0xc6c450c46f71ad568d8bfa16ca597906eb017c71", "jailThreshold": 48}}`
+	if err := json.Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("failed to unmarshal config: %v", err)
+	}
+	blob, err := config.NormalizeAddresses()
+	if err != nil {
+		t.Fatalf("failed to normalize addresses: %v", err)
+	}
+	for _, want := range []string{
+		`"ishikariAdminAddress": "` + config.POSA.IshikariAdminMultiSig.Hex() + `"`,
+		`"` + config.POSA.IshikariInitialValidators[0].Hex() + `"`,
+		`"` + config.POSA.IshikariInitialManagers[0].Hex() + `"`,
+		`"punishContractAddress": "` + config.POSA.PunishContractAddress.Hex() + `"`,
+	} {
+		if !strings.Contains(string(blob), want) {
+			t.Errorf("normalized config misses %s:\n%s", want, blob)
+		}
+	}
+	// Apart from the address casing, the output must match the regular export
+	exported, err := config.ExportJSON()
+	if err != nil {
+		t.Fatalf("failed to export config: %v", err)
+	}
+	if !bytes.Equal(bytes.ToLower(blob), bytes.ToLower(exported)) {
+		t.Errorf("normalized config mismatch:\nhave %s\nwant %s", blob, exported)
+	}
+	if want := "0x20b9A60C5a2137259ce81E45A1310A754270753b"; config.POSA.IshikariInitialValidators[0].Hex() != want {
+		t.Errorf("checksum mismatch: have %s, want %s", config.POSA.IshikariInitialValidators[0].Hex(), want)
+	}
+	dec := new(ChainConfig)
+	if err := json.Unmarshal(blob, dec); err != nil {
+		t.Fatalf("failed to unmarshal normalized config: %v", err)
+	}
+	config.ConfigVersion = ConfigSchemaVersion // stamped on encoding
+	if !dec.Equal(&config) {
+		t.Errorf("normalized config decodes differently:\nhave %v\nwant %v", dec, &config)
+	}
+}
+
+func TestChainConfigAsMap(t *testing.T) {
+	fields := MainnetChainConfig.AsMap()
+	if have, ok := fields["ishikariBlock"].(string); !ok || have != "11171299" {
+		t.Errorf("ishikariBlock mismatch: have %#v, want \"11171299\"", fields["ishikariBlock"])
+	}
+	if have, ok := fields["berlinBlock"].(string); !ok || have != "0" {
+		t.Errorf("berlinBlock mismatch: have %#v, want \"0\"", fields["berlinBlock"])
+	}
+	if have := fmt.Sprint(fields["chainId"]); have != "126" {
+		t.Errorf("chainId mismatch: have %v, want 126", have)
+	}
+	if _, ok := fields["ewasmBlock"]; ok {
+		t.Errorf("unset fork present")
+	}
+	posa, ok := fields["posa"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("posa not a nested object: %#v", fields["posa"])
+	}
+	validators, ok := posa["ishikariInitialValidators"].([]interface{})
+	if !ok || len(validators) != len(MainnetChainConfig.POSA.IshikariInitialValidators) {
+		t.Fatalf("validators mismatch: %#v", posa["ishikariInitialValidators"])
+	}
+	if have, want := validators[0], strings.ToLower(MainnetChainConfig.POSA.IshikariInitialValidators[0].Hex()); have != want {
+		t.Errorf("validator mismatch: have %v, want %v", have, want)
+	}
+	// Templates must be able to reference the config by JSON key
+	tmpl := template.Must(template.New("").Parse(`{{.config.ishikariBlock}} {{.config.posa.period}}`))
+	var out strings.Builder
+	if err := tmpl.Execute(&out, map[string]interface{}{"config": fields}); err != nil {
+		t.Fatalf("failed to execute template: %v", err)
+	}
+	if have, want := out.String(), "11171299 3"; have != want {
+		t.Errorf("template output mismatch: have %q, want %q", have, want)
+	}
+}
+
+func TestChainConfigCanonicalJSON(t *testing.T) {
+	// Build the mainnet config a second time from a differently shaped encoding:
+	// compact, with keys in a different order
+	blob, err := json.Marshal(MainnetChainConfig.AsMap())
+	if err != nil {
+		t.Fatalf("failed to encode config map: %v", err)
+	}
+	rebuilt := new(ChainConfig)
+	if err := json.Unmarshal(blob, rebuilt); err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+	want, err := MainnetChainConfig.CanonicalJSON()
+	if err != nil {
+		t.Fatalf("failed to canonicalize config: %v", err)
+	}
+	have, err := rebuilt.CanonicalJSON()
+	if err != nil {
+		t.Fatalf("failed to canonicalize rebuilt config: %v", err)
+	}
+	if string(have) != string(want) {
+		t.Errorf("canonical encodings differ:\nhave %s\nwant %s", have, want)
+	}
+	// Check the canonical form itself: no whitespace, sorted keys, decimal blocks
+	canon := string(want)
+	if strings.ContainsAny(canon, " \n\t") {
+		t.Errorf("canonical encoding contains whitespace: %s", canon)
+	}
+	if !strings.Contains(canon, `"ishikariBlock":"11171299"`) {
+		t.Errorf("fork block not a decimal string: %s", canon)
+	}
+	if a, b, c := strings.Index(canon, `"berlinBlock"`), strings.Index(canon, `"chainId"`), strings.Index(canon, `"posa"`); !(a < b && b < c) {
+		t.Errorf("keys not sorted: %s", canon)
+	}
+}
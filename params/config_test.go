@@ -17,9 +17,18 @@
 package params
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"math/big"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
 )
 
 func TestCheckCompatible(t *testing.T) {
@@ -46,6 +55,7 @@ func TestCheckCompatible(t *testing.T) {
 				StoredConfig: big.NewInt(0),
 				NewConfig:    nil,
 				RewindTo:     0,
+				Direction:    CompatStoredAhead,
 			},
 		},
 		{
@@ -57,6 +67,7 @@ func TestCheckCompatible(t *testing.T) {
 				StoredConfig: big.NewInt(0),
 				NewConfig:    big.NewInt(1),
 				RewindTo:     0,
+				Direction:    CompatStoredAhead,
 			},
 		},
 		{
@@ -68,6 +79,7 @@ func TestCheckCompatible(t *testing.T) {
 				StoredConfig: big.NewInt(10),
 				NewConfig:    big.NewInt(20),
 				RewindTo:     9,
+				Direction:    CompatStoredAhead,
 			},
 		},
 		{
@@ -85,6 +97,7 @@ func TestCheckCompatible(t *testing.T) {
 				StoredConfig: nil,
 				NewConfig:    big.NewInt(31),
 				RewindTo:     30,
+				Direction:    CompatNewAhead,
 			},
 		},
 	}
@@ -96,3 +109,2228 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+func TestIshikariWindow(t *testing.T) {
+	tests := []struct {
+		config   *ChainConfig
+		num      *big.Int
+		preFork  bool
+		inWindow bool
+	}{
+		{MainnetChainConfig, nil, false, false},
+		{MainnetChainConfig, big.NewInt(0), true, false},
+		{MainnetChainConfig, big.NewInt(11171298), true, false},
+		{MainnetChainConfig, big.NewInt(11171299), false, true},
+		{MainnetChainConfig, big.NewInt(11171398), false, true},
+		{MainnetChainConfig, big.NewInt(11171399), false, false},
+		{AllEthashProtocolChanges, big.NewInt(11171299), false, false},
+	}
+	for i, tt := range tests {
+		if have := tt.config.IsPreIshikari(tt.num); have != tt.preFork {
+			t.Errorf("test %d: IsPreIshikari(%v) mismatch: have %v, want %v", i, tt.num, have, tt.preFork)
+		}
+		if have := tt.config.IsWithinIshikariWindow(tt.num, 100); have != tt.inWindow {
+			t.Errorf("test %d: IsWithinIshikariWindow(%v, 100) mismatch: have %v, want %v", i, tt.num, have, tt.inWindow)
+		}
+	}
+}
+
+func TestCompatErrorHugeForkBlock(t *testing.T) {
+	huge := new(big.Int).Lsh(big.NewInt(1), 65)
+
+	err := newCompatError("Ishikari fork block", huge, nil)
+	if err.RewindTo != math.MaxUint64 || !err.RewindSaturated {
+		t.Errorf("rewind mismatch: have %d (saturated %v), want %d (saturated true)", err.RewindTo, err.RewindSaturated, uint64(math.MaxUint64))
+	}
+	err = newCompatError("Ishikari fork block", big.NewInt(10), huge)
+	if err.RewindTo != 9 || err.RewindSaturated {
+		t.Errorf("rewind mismatch: have %d (saturated %v), want 9 (saturated false)", err.RewindTo, err.RewindSaturated)
+	}
+	err = newCompatError("Ishikari fork block", huge, huge)
+	if err.RewindTo != math.MaxUint64 || !err.RewindSaturated {
+		t.Errorf("rewind mismatch: have %d (saturated %v), want %d (saturated true)", err.RewindTo, err.RewindSaturated, uint64(math.MaxUint64))
+	}
+}
+
+func TestManagerForValidator(t *testing.T) {
+	posa := MainnetChainConfig.POSA
+
+	manager, ok := posa.ManagerForValidator(common.HexToAddress("0x1105c97ffbd985600e6dc8e06e477b99d0a9ff39"))
+	if !ok || manager != common.HexToAddress("0x65E958D3EA7e60F33098dc665B0C8B7Dc563FA72") {
+		t.Errorf("manager mismatch: have %x (found %v), want 0x65E958D3EA7e60F33098dc665B0C8B7Dc563FA72", manager, ok)
+	}
+	for i, validator := range posa.IshikariInitialValidators {
+		if manager, ok := posa.ManagerForValidator(validator); !ok || manager != posa.IshikariInitialManagers[i] {
+			t.Errorf("validator %d: manager mismatch: have %x (found %v), want %x", i, manager, ok, posa.IshikariInitialManagers[i])
+		}
+	}
+	if _, ok := posa.ManagerForValidator(common.Address{}); ok {
+		t.Errorf("unknown validator resolved to a manager")
+	}
+	misaligned := &POSAConfig{
+		IshikariInitialValidators: posa.IshikariInitialValidators,
+		IshikariInitialManagers:   posa.IshikariInitialManagers[:1],
+	}
+	if _, ok := misaligned.ManagerForValidator(posa.IshikariInitialValidators[0]); ok {
+		t.Errorf("misaligned config resolved to a manager")
+	}
+}
+
+func TestBaseFeeParams(t *testing.T) {
+	if have := MainnetChainConfig.BaseFeeChangeDenom(); have != 8 {
+		t.Errorf("default base fee change denominator mismatch: have %d, want 8", have)
+	}
+	if have := MainnetChainConfig.ElasticityMult(); have != 2 {
+		t.Errorf("default elasticity multiplier mismatch: have %d, want 2", have)
+	}
+	denom, elasticity := uint64(50), uint64(4)
+	config := &ChainConfig{BaseFeeChangeDenominator: &denom, ElasticityMultiplier: &elasticity}
+	if have := config.BaseFeeChangeDenom(); have != 50 {
+		t.Errorf("base fee change denominator mismatch: have %d, want 50", have)
+	}
+	if have := config.ElasticityMult(); have != 4 {
+		t.Errorf("elasticity multiplier mismatch: have %d, want 4", have)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("valid fee params rejected: %v", err)
+	}
+	zero := uint64(0)
+	if err := (&ChainConfig{BaseFeeChangeDenominator: &zero}).Validate(); err == nil {
+		t.Errorf("zero base fee change denominator accepted")
+	}
+}
+
+func TestCheckForkOrder(t *testing.T) {
+	tests := []struct {
+		forks []scheduledFork
+		fail  bool
+	}{
+		{forks: []scheduledFork{{name: "a", block: big.NewInt(0)}, {name: "b", block: big.NewInt(10)}}},
+		{forks: []scheduledFork{{name: "a", block: big.NewInt(0)}, {name: "b", block: big.NewInt(10)}, {name: "c"}}},
+		{forks: []scheduledFork{{name: "a", block: big.NewInt(10)}, {name: "b", block: big.NewInt(0)}}, fail: true},
+
+		// Skipped mandatory and optional forks
+		{forks: []scheduledFork{{name: "a", block: big.NewInt(0)}, {name: "b"}, {name: "c", block: big.NewInt(10)}}, fail: true},
+		{forks: []scheduledFork{{name: "a", block: big.NewInt(0)}, {name: "b", optional: true}, {name: "c", block: big.NewInt(10)}}},
+	}
+	for i, tt := range tests {
+		err := checkForkOrder(tt.forks)
+		if tt.fail && err == nil {
+			t.Errorf("test %d: expected fork ordering error", i)
+		}
+		if !tt.fail && err != nil {
+			t.Errorf("test %d: unexpected fork ordering error: %v", i, err)
+		}
+	}
+	if err := MainnetChainConfig.CheckConfigForkOrder(); err != nil {
+		t.Errorf("mainnet fork ordering rejected: %v", err)
+	}
+}
+
+func TestPOSAConfigString(t *testing.T) {
+	want := "posa(period=3 epoch=100 validators=11 managers=11 admin=0xD4139cc315164d4dcC696a18902F2e6b7B5D3de8)"
+	if have := MainnetChainConfig.POSA.String(); have != want {
+		t.Errorf("string mismatch:\nhave: %s\nwant: %s", have, want)
+	}
+	posa := &POSAConfig{
+		Period:                    3,
+		Epoch:                     200,
+		IshikariInitialValidators: []common.Address{common.HexToAddress("0x20b9a60c5a2137259ce81e45a1310a754270753b")},
+		IshikariInitialManagers:   []common.Address{common.HexToAddress("0xc6c450c46f71ad568d8bfa16ca597906eb017c71")},
+	}
+	want = "posa(period=3 epoch=200 validators=[0x20b9A60C5a2137259ce81E45A1310A754270753b] managers=[0xc6C450C46F71AD568d8BfA16Ca597906eb017c71] admin=0x0000000000000000000000000000000000000000)"
+	if have := posa.StringVerbose(); have != want {
+		t.Errorf("verbose string mismatch:\nhave: %s\nwant: %s", have, want)
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	config := &ChainConfig{ConstantinopleBlock: big.NewInt(30), YoloV3Block: big.NewInt(50)}
+	nums := []*big.Int{big.NewInt(0), big.NewInt(29), big.NewInt(30), big.NewInt(49), big.NewInt(50), big.NewInt(100)}
+
+	var petersburg, berlin []bool
+	for _, num := range nums {
+		petersburg = append(petersburg, config.IsPetersburg(num))
+		berlin = append(berlin, config.IsBerlin(num))
+	}
+	config.ApplyDefaults()
+
+	if config.PetersburgBlock == nil || config.PetersburgBlock.Cmp(big.NewInt(30)) != 0 {
+		t.Errorf("petersburg block mismatch: have %v, want 30", config.PetersburgBlock)
+	}
+	if config.PetersburgBlock == config.ConstantinopleBlock {
+		t.Errorf("petersburg block aliases constantinople block")
+	}
+	if config.BerlinBlock == nil || config.BerlinBlock.Cmp(big.NewInt(50)) != 0 {
+		t.Errorf("berlin block mismatch: have %v, want 50", config.BerlinBlock)
+	}
+	for i, num := range nums {
+		if have := config.IsPetersburg(num); have != petersburg[i] {
+			t.Errorf("block %v: IsPetersburg changed: have %v, want %v", num, have, petersburg[i])
+		}
+		if have := config.IsBerlin(num); have != berlin[i] {
+			t.Errorf("block %v: IsBerlin changed: have %v, want %v", num, have, berlin[i])
+		}
+	}
+	// Explicit blocks must be left alone
+	config = &ChainConfig{ConstantinopleBlock: big.NewInt(30), PetersburgBlock: big.NewInt(40), BerlinBlock: big.NewInt(10), YoloV3Block: big.NewInt(50)}
+	config.ApplyDefaults()
+	if config.PetersburgBlock.Cmp(big.NewInt(40)) != 0 || config.BerlinBlock.Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("explicit fork blocks overridden: petersburg %v, berlin %v", config.PetersburgBlock, config.BerlinBlock)
+	}
+}
+
+func TestIsCVE202139137(t *testing.T) {
+	config := &ChainConfig{CVE_2021_39137Block: big.NewInt(100)}
+	tests := []struct {
+		num  *big.Int
+		want bool
+	}{
+		{nil, false},
+		{big.NewInt(0), false},
+		{big.NewInt(99), false},
+		{big.NewInt(100), false}, // the fork block itself is still processed with the vulnerable code
+		{big.NewInt(101), true},
+	}
+	for _, tt := range tests {
+		if have := config.IsCVE202139137(tt.num); have != tt.want {
+			t.Errorf("block %v: IsCVE202139137 mismatch: have %v, want %v", tt.num, have, tt.want)
+		}
+		if tt.num != nil {
+			if have := config.Rules(tt.num).IsCVE_2021_39137BlockPassed; have != tt.want {
+				t.Errorf("block %v: rules mismatch: have %v, want %v", tt.num, have, tt.want)
+			}
+		}
+	}
+	if !new(ChainConfig).IsCVE202139137(big.NewInt(0)) {
+		t.Errorf("unset CVE fork block should always apply the fix")
+	}
+}
+
+func TestCVE202139137Boundary(t *testing.T) {
+	tests := []struct {
+		config *ChainConfig
+		num    int64
+		passed bool
+	}{
+		// Mainnet: the malicious transaction is in block 2509228 itself
+		{MainnetChainConfig, 2509227, false},
+		{MainnetChainConfig, 2509228, false},
+		{MainnetChainConfig, 2509229, true},
+
+		// Testnet: only the genesis block is before the fix
+		{TestnetChainConfig, 0, false},
+		{TestnetChainConfig, 1, true},
+		{TestnetChainConfig, 2, true},
+	}
+	for _, tt := range tests {
+		num := big.NewInt(tt.num)
+		if have := tt.config.IsCVE202139137(num); have != tt.passed {
+			t.Errorf("chain %v block %d: IsCVE202139137 mismatch: have %v, want %v", tt.config.ChainID, tt.num, have, tt.passed)
+		}
+		if have := tt.config.Rules(num).IsCVE_2021_39137BlockPassed; have != tt.passed {
+			t.Errorf("chain %v block %d: rules mismatch: have %v, want %v", tt.config.ChainID, tt.num, have, tt.passed)
+		}
+	}
+}
+
+func TestPOSAValidatorSetEdits(t *testing.T) {
+	var (
+		base      = TestnetChainConfig.POSA
+		validator = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		manager   = common.HexToAddress("0x2222222222222222222222222222222222222222")
+	)
+	added, err := base.AddValidatorPair(validator, manager)
+	if err != nil {
+		t.Fatalf("failed to add validator: %v", err)
+	}
+	if len(base.IshikariInitialValidators) != 4 || len(base.IshikariInitialManagers) != 4 {
+		t.Fatalf("original config modified")
+	}
+	if m, ok := added.ManagerForValidator(validator); !ok || m != manager {
+		t.Errorf("added pair misaligned: have %x (found %v), want %x", m, ok, manager)
+	}
+	if _, err := added.AddValidatorPair(validator, manager); err == nil {
+		t.Errorf("duplicate validator accepted")
+	}
+	if _, err := base.AddValidatorPair(common.Address{}, manager); err == nil {
+		t.Errorf("zero validator accepted")
+	}
+	if _, err := base.AddValidatorPair(validator, common.Address{}); err == nil {
+		t.Errorf("zero manager accepted")
+	}
+	removed, err := added.RemoveValidator(validator)
+	if err != nil {
+		t.Fatalf("failed to remove validator: %v", err)
+	}
+	if !reflect.DeepEqual(removed, base) {
+		t.Errorf("add-remove round trip mismatch: have %v, want %v", removed.StringVerbose(), base.StringVerbose())
+	}
+	if len(added.IshikariInitialValidators) != 5 {
+		t.Errorf("config modified by removal")
+	}
+	// Removing from the middle keeps the remaining pairs aligned
+	middle, err := base.RemoveValidator(base.IshikariInitialValidators[1])
+	if err != nil {
+		t.Fatalf("failed to remove validator: %v", err)
+	}
+	for i, v := range middle.IshikariInitialValidators {
+		want, _ := base.ManagerForValidator(v)
+		if middle.IshikariInitialManagers[i] != want {
+			t.Errorf("pair %d misaligned after removal", i)
+		}
+	}
+	if !reflect.DeepEqual(base.IshikariInitialValidators, TestnetChainConfig.POSA.IshikariInitialValidators) {
+		t.Errorf("original config modified by removal")
+	}
+	if _, err := base.RemoveValidator(validator); err == nil {
+		t.Errorf("removal of unknown validator accepted")
+	}
+}
+
+func TestCompatErrorDirection(t *testing.T) {
+	stored := &ChainConfig{IshikariBlock: big.NewInt(100)}
+
+	// Fork moved earlier into the already processed chain
+	err := stored.CheckCompatible(&ChainConfig{IshikariBlock: big.NewInt(50)}, 150)
+	if err == nil || err.Direction != CompatNewAhead || err.RewindTo != 49 {
+		t.Fatalf("fork moved earlier: unexpected error %v", err)
+	}
+	if !strings.Contains(err.Error(), "rewind to block 49") {
+		t.Errorf("missing remediation hint: %v", err)
+	}
+	// Fork moved later, the stored chain already passed it
+	err = stored.CheckCompatible(&ChainConfig{IshikariBlock: big.NewInt(200)}, 150)
+	if err == nil || err.Direction != CompatStoredAhead || err.RewindTo != 99 {
+		t.Fatalf("fork moved later: unexpected error %v", err)
+	}
+	if !strings.Contains(err.Error(), "downgrade is unsupported") {
+		t.Errorf("missing remediation hint: %v", err)
+	}
+	// Fork newly scheduled in the past
+	err = new(ChainConfig).CheckCompatible(&ChainConfig{IshikariBlock: big.NewInt(50)}, 150)
+	if err == nil || err.Direction != CompatNewAhead {
+		t.Fatalf("fork scheduled in the past: unexpected error %v", err)
+	}
+}
+
+func TestSetForkChecked(t *testing.T) {
+	head := uint64(11200000)
+
+	// Moving a past fork alters the history
+	_, err := MainnetChainConfig.SetForkChecked("ishikariBlock", big.NewInt(11180000), head)
+	compat, ok := err.(*ConfigCompatError)
+	if !ok {
+		t.Fatalf("past fork edit: expected compatibility error, have %v", err)
+	}
+	if compat.RewindTo != 11171298 {
+		t.Errorf("past fork edit: rewind mismatch: have %d, want 11171298", compat.RewindTo)
+	}
+	// Moving a future fork is fine
+	config, err := TestnetChainConfig.SetForkChecked("ishikari", big.NewInt(11400000), head)
+	if err != nil {
+		t.Fatalf("future fork edit rejected: %v", err)
+	}
+	if config.IshikariBlock.Cmp(big.NewInt(11400000)) != 0 {
+		t.Errorf("fork block mismatch: have %v, want 11400000", config.IshikariBlock)
+	}
+	if TestnetChainConfig.IshikariBlock.Cmp(big.NewInt(11321699)) != 0 {
+		t.Errorf("original config modified")
+	}
+	if _, err := MainnetChainConfig.SetForkChecked("shanghai", big.NewInt(1), head); err == nil {
+		t.Errorf("unknown fork accepted")
+	}
+}
+
+func TestRequiredClientVersion(t *testing.T) {
+	config := TestnetChainConfig.Clone()
+	config.MinClientVersions = map[string]string{
+		"berlin":                "v1.0.4",
+		"ishikari":              "v1.2.0",
+		"ishikariPatch002Block": "v1.2.10",
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+	tests := []struct {
+		num  int64
+		want string
+	}{
+		{0, "v1.0.4"},
+		{11321698, "v1.0.4"},
+		{11321699, "v1.2.0"},
+		{12162885, "v1.2.0"},
+		{12162886, "v1.2.10"},
+	}
+	for _, tt := range tests {
+		have, ok := config.RequiredClientVersion(big.NewInt(tt.num))
+		if !ok || have != tt.want {
+			t.Errorf("block %d: version mismatch: have %q (found %v), want %q", tt.num, have, ok, tt.want)
+		}
+	}
+	if _, ok := MainnetChainConfig.RequiredClientVersion(big.NewInt(0)); ok {
+		t.Errorf("version required without any requirement configured")
+	}
+	config.MinClientVersions = map[string]string{"shanghai": "v1.2.0"}
+	if err := config.Validate(); err == nil {
+		t.Errorf("unknown fork name accepted")
+	}
+	for _, version := range []string{"1.2", "v1.2.x", "v01.2.0", "version1"} {
+		config.MinClientVersions = map[string]string{"ishikari": version}
+		if err := config.Validate(); err == nil {
+			t.Errorf("malformed version %q accepted", version)
+		}
+	}
+}
+
+func TestRulesShared(t *testing.T) {
+	num := big.NewInt(11171299)
+
+	rules, shared := MainnetChainConfig.Rules(num), MainnetChainConfig.RulesShared(num)
+	if !reflect.DeepEqual(rules, shared) {
+		t.Errorf("rules mismatch: have %+v, want %+v", shared, rules)
+	}
+	if shared.ChainID != MainnetChainConfig.ChainID {
+		t.Errorf("shared rules copied the chain ID")
+	}
+	if rules.ChainID == MainnetChainConfig.ChainID {
+		t.Errorf("rules didn't copy the chain ID")
+	}
+	if id := new(ChainConfig).RulesShared(num).ChainID; id == nil || id.Sign() != 0 {
+		t.Errorf("missing chain ID not defaulted to zero: %v", id)
+	}
+	new(ChainConfig).RulesShared(num).ChainID.SetUint64(1)
+	if id := new(ChainConfig).RulesShared(num).ChainID; id.Sign() != 0 {
+		t.Errorf("missing chain ID shared between configs: %v", id)
+	}
+	var nilConfig *ChainConfig
+	nilConfig.RulesShared(num).ChainID.SetUint64(1)
+	if id := nilConfig.RulesShared(num).ChainID; id == nil || id.Sign() != 0 {
+		t.Errorf("nil config chain ID shared between calls: %v", id)
+	}
+}
+
+func BenchmarkRules(b *testing.B) {
+	num := big.NewInt(11171299)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		MainnetChainConfig.Rules(num)
+	}
+}
+
+func BenchmarkRulesShared(b *testing.B) {
+	num := big.NewInt(11171299)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		MainnetChainConfig.RulesShared(num)
+	}
+}
+
+func TestIsValidatorSetFrozen(t *testing.T) {
+	tests := []struct {
+		config *ChainConfig
+		num    int64
+		frozen bool
+	}{
+		{MainnetChainConfig, 11171298, false},
+		{MainnetChainConfig, 11171299, true}, // activation block
+		{MainnetChainConfig, 11171349, true}, // mid-window
+		{MainnetChainConfig, 11171398, true},
+		{MainnetChainConfig, 11171399, false}, // just past the window
+		{AllCliqueProtocolChanges, 0, false},
+		{&ChainConfig{POSA: &POSAConfig{Epoch: 100}}, 0, false},
+	}
+	for i, tt := range tests {
+		if have := tt.config.IsValidatorSetFrozen(big.NewInt(tt.num)); have != tt.frozen {
+			t.Errorf("test %d: block %d: frozen mismatch: have %v, want %v", i, tt.num, have, tt.frozen)
+		}
+	}
+}
+
+func TestImminentForks(t *testing.T) {
+	config := &ChainConfig{
+		HomesteadBlock:        big.NewInt(0),
+		CVE_2021_39137Block:   big.NewInt(1050),
+		IshikariBlock:         big.NewInt(1100),
+		IshikariPatch001Block: big.NewInt(11000),
+	}
+	have := config.ImminentForks(1000, 500)
+	if want := []string{"ishikari"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("imminent forks mismatch: have %v, want %v", have, want)
+	}
+	if have := config.ImminentForks(1100, 500); len(have) != 0 {
+		t.Errorf("activated fork reported as imminent: %v", have)
+	}
+	if have := config.ImminentForks(10999, 1); !reflect.DeepEqual(have, []string{"ishikariPatch001"}) {
+		t.Errorf("fork at window end not reported: %v", have)
+	}
+	if have := config.ImminentForks(math.MaxUint64-1, math.MaxUint64); len(have) != 0 {
+		t.Errorf("unexpected imminent forks near the uint64 limit: %v", have)
+	}
+}
+
+func TestPruneFutureForks(t *testing.T) {
+	config := MainnetChainConfig.Clone()
+	config.MinClientVersions = map[string]string{"berlin": "v1.0.4", "ishikariBlock": "v1.2.0"}
+
+	pruned := config.PruneFutureForks(11171298)
+	if pruned.IshikariBlock != nil || pruned.IshikariPatch001Block != nil || pruned.IshikariPatch002Block != nil {
+		t.Errorf("future Ishikari forks retained: %v", pruned)
+	}
+	if !configNumEqual(pruned.BerlinBlock, big.NewInt(0)) || !configNumEqual(pruned.CVE_2021_39137Block, big.NewInt(2509228)) {
+		t.Errorf("past forks pruned: %v", pruned)
+	}
+	if want := map[string]string{"berlin": "v1.0.4"}; !reflect.DeepEqual(pruned.MinClientVersions, want) {
+		t.Errorf("client versions mismatch: have %v, want %v", pruned.MinClientVersions, want)
+	}
+	if err := pruned.CheckConfigForkOrder(); err != nil {
+		t.Errorf("pruned config has invalid fork order: %v", err)
+	}
+	if MainnetChainConfig.IshikariBlock == nil {
+		t.Errorf("original config modified")
+	}
+	if pruned := config.PruneFutureForks(11171299); !configNumEqual(pruned.IshikariPatch002Block, big.NewInt(11171299)) {
+		t.Errorf("fork at head pruned")
+	}
+}
+
+func TestEngineTiming(t *testing.T) {
+	tests := []struct {
+		config        *ChainConfig
+		period, epoch uint64
+		ok            bool
+	}{
+		{AllEthashProtocolChanges, 0, 0, false},
+		{&ChainConfig{Clique: &CliqueConfig{Period: 15, Epoch: 30000}}, 15, 30000, true},
+		{MainnetChainConfig, 3, 100, true},
+	}
+	for i, tt := range tests {
+		period, ok := tt.config.BlockPeriod()
+		if period != tt.period || ok != tt.ok {
+			t.Errorf("test %d: period mismatch: have %d (%v), want %d (%v)", i, period, ok, tt.period, tt.ok)
+		}
+		epoch, ok := tt.config.EpochLength()
+		if epoch != tt.epoch || ok != tt.ok {
+			t.Errorf("test %d: epoch mismatch: have %d (%v), want %d (%v)", i, epoch, ok, tt.epoch, tt.ok)
+		}
+	}
+}
+
+func TestBerlinYoloV3Conflict(t *testing.T) {
+	config := AllEthashProtocolChanges.Clone()
+	config.YoloV3Block = big.NewInt(0)
+	if err := config.CheckConfigForkOrder(); err != nil {
+		t.Errorf("equal berlin and yolov3 blocks rejected: %v", err)
+	}
+	config.BerlinBlock = nil
+	if err := config.CheckConfigForkOrder(); err != nil {
+		t.Errorf("yolov3 block alone rejected: %v", err)
+	}
+	config.BerlinBlock, config.YoloV3Block = big.NewInt(0), big.NewInt(10)
+	if err := config.CheckConfigForkOrder(); err == nil {
+		t.Errorf("conflicting berlin and yolov3 blocks accepted")
+	}
+}
+
+func TestForkBlockByName(t *testing.T) {
+	config := &ChainConfig{
+		IshikariPatch002Block: big.NewInt(1200),
+		CVE_2021_39137Block:   big.NewInt(300),
+	}
+	if block, ok := config.ForkBlockByName("ishikariPatch002Block"); !ok || block.Cmp(big.NewInt(1200)) != 0 {
+		t.Errorf("ishikariPatch002Block: have (%v, %v), want (1200, true)", block, ok)
+	}
+	if block, ok := config.ForkBlockByName("ishikariPatch002"); !ok || block.Cmp(big.NewInt(1200)) != 0 {
+		t.Errorf("ishikariPatch002: have (%v, %v), want (1200, true)", block, ok)
+	}
+	if block, ok := config.ForkBlockByName("cve_2021_39137"); !ok || block.Cmp(big.NewInt(300)) != 0 {
+		t.Errorf("cve_2021_39137: have (%v, %v), want (300, true)", block, ok)
+	}
+	if block, ok := config.ForkBlockByName("berlinBlock"); !ok || block != nil {
+		t.Errorf("berlinBlock: have (%v, %v), want (<nil>, true)", block, ok)
+	}
+	if block, ok := config.ForkBlockByName("atlantisBlock"); ok || block != nil {
+		t.Errorf("atlantisBlock: have (%v, %v), want (<nil>, false)", block, ok)
+	}
+}
+
+func TestPOSAEpochFitsValidators(t *testing.T) {
+	validators := make([]common.Address, 11)
+	for i := range validators {
+		validators[i] = common.BigToAddress(big.NewInt(int64(i + 1)))
+	}
+	tests := []struct {
+		epoch    uint64
+		ishikari uint64
+		ok       bool
+	}{
+		{epoch: 3, ishikari: 2, ok: false},
+		{epoch: 11, ishikari: 10, ok: false},
+		{epoch: 12, ishikari: 11, ok: true},
+		{epoch: 100, ishikari: 99, ok: true},
+	}
+	for i, tt := range tests {
+		posa := &POSAConfig{
+			Period:                    3,
+			Epoch:                     tt.epoch,
+			IshikariInitialValidators: validators,
+			IshikariInitialManagers:   validators,
+		}
+		err := posa.Validate(&ChainConfig{IshikariBlock: new(big.Int).SetUint64(tt.ishikari)})
+		if (err == nil) != tt.ok {
+			t.Errorf("test %d: epoch %d with %d validators: have err %v, want ok %v", i, tt.epoch, len(validators), err, tt.ok)
+		}
+	}
+}
+
+func TestWithChainID(t *testing.T) {
+	config := MainnetChainConfig.WithChainID(big.NewInt(9999))
+	if config.ChainID.Cmp(big.NewInt(9999)) != 0 {
+		t.Errorf("derived chain ID mismatch: have %v, want 9999", config.ChainID)
+	}
+	if MainnetChainConfig.ChainID.Cmp(big.NewInt(126)) != 0 {
+		t.Errorf("mainnet chain ID modified: have %v, want 126", MainnetChainConfig.ChainID)
+	}
+	if config.POSA == MainnetChainConfig.POSA || config.IshikariBlock == MainnetChainConfig.IshikariBlock {
+		t.Errorf("derived config shares state with mainnet")
+	}
+	for _, id := range []*big.Int{nil, big.NewInt(0), big.NewInt(-1)} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("chain ID %v accepted", id)
+				}
+			}()
+			MainnetChainConfig.WithChainID(id)
+		}()
+	}
+}
+
+func TestWithFork(t *testing.T) {
+	config := MainnetChainConfig.Clone().WithFork("ishikariBlock", big.NewInt(5)).WithFork("ewasm", big.NewInt(100))
+	if !config.IsKCCIshikari(big.NewInt(5)) {
+		t.Errorf("ishikari not active at the overridden block")
+	}
+	if !config.IsEWASM(big.NewInt(100)) {
+		t.Errorf("ewasm not active at the overridden block")
+	}
+	if MainnetChainConfig.IsKCCIshikari(big.NewInt(5)) {
+		t.Errorf("base config modified")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("unknown fork accepted")
+		}
+	}()
+	config.WithFork("atlantisBlock", big.NewInt(1))
+}
+
+func TestCheckCompatibleBatch(t *testing.T) {
+	var (
+		compatible   = &ChainConfig{HomesteadBlock: big.NewInt(10)}
+		incompatible = &ChainConfig{HomesteadBlock: big.NewInt(20)}
+		newcfg       = &ChainConfig{HomesteadBlock: big.NewInt(10)}
+	)
+	errs := newcfg.CheckCompatibleBatch([]*ChainConfig{compatible, incompatible}, 30)
+	if len(errs) != 1 {
+		t.Fatalf("incompatibility count mismatch: have %d, want 1", len(errs))
+	}
+	if _, ok := errs[0]; ok {
+		t.Errorf("compatible config reported: %v", errs[0])
+	}
+	if err := errs[1]; err == nil || err.What != "Homestead fork block" || err.RewindTo != 9 {
+		t.Errorf("incompatible config error mismatch: %v", err)
+	}
+}
+
+func TestValidateWithReport(t *testing.T) {
+	report := MainnetChainConfig.ValidateWithReport()
+	if len(report.Errors) != 0 {
+		t.Fatalf("mainnet config reported errors: %v", report.Errors)
+	}
+	for _, check := range []string{"fork-order", "posa", "chain-id"} {
+		var found bool
+		for _, have := range report.Checks {
+			found = found || have == check
+		}
+		if !found {
+			t.Errorf("check %q missing from report: %v", check, report.Checks)
+		}
+	}
+	// Broken configs should report all their errors, not only the first one
+	zero := uint64(0)
+	config := &ChainConfig{ChainID: big.NewInt(-1), BaseFeeChangeDenominator: &zero}
+	report = config.ValidateWithReport()
+	if len(report.Errors) != 2 {
+		t.Errorf("error count mismatch: have %v, want 2", report.Errors)
+	}
+	if err := config.Validate(); err == nil || err.Error() != report.Errors[0] {
+		t.Errorf("validate error mismatch: have %v, want %v", err, report.Errors[0])
+	}
+	// Missing chain IDs are only warned about
+	report = new(ChainConfig).ValidateWithReport()
+	if len(report.Errors) != 0 || len(report.Warnings) != 1 {
+		t.Errorf("chainless config: have errors %v warnings %v, want 0 and 1", report.Errors, report.Warnings)
+	}
+}
+
+func TestIsSyntheticFork(t *testing.T) {
+	config := new(ChainConfig)
+	for _, name := range []string{"cve_2021_39137", "cve_2021_39137Block"} {
+		if !config.IsSyntheticFork(name) {
+			t.Errorf("%s not synthetic", name)
+		}
+	}
+	for _, name := range []string{"ishikari", "ishikariBlock", "berlin", "unknown"} {
+		if config.IsSyntheticFork(name) {
+			t.Errorf("%s synthetic", name)
+		}
+	}
+}
+
+func TestGenesisValidatorSetBytes(t *testing.T) {
+	posa := &POSAConfig{
+		Period: 3,
+		Epoch:  100,
+		IshikariInitialValidators: []common.Address{
+			common.HexToAddress("0x0000000000000000000000000000000000000001"),
+			common.HexToAddress("0x0000000000000000000000000000000000000002"),
+		},
+		IshikariInitialManagers: []common.Address{
+			common.HexToAddress("0x0000000000000000000000000000000000000011"),
+			common.HexToAddress("0x0000000000000000000000000000000000000012"),
+		},
+		IshikariAdminMultiSig: common.HexToAddress("0x00000000000000000000000000000000000000aa"),
+	}
+	want := common.FromHex("b7c13135" + // initialize(address[],address[],uint256[],address,address,address,address,address,uint256)
+		"0000000000000000000000000000000000000000000000000000000000000120" + // validators offset
+		"0000000000000000000000000000000000000000000000000000000000000180" + // managers offset
+		"00000000000000000000000000000000000000000000000000000000000001e0" + // fee shares offset
+		"00000000000000000000000000000000000000000000000000000000000000aa" + // admin
+		"000000000000000000000000000000000000000000000000000000000000f333" + // validators contract
+		"000000000000000000000000000000000000000000000000000000000000f444" + // punish contract
+		"000000000000000000000000000000000000000000000000000000000000f555" + // proposal contract
+		"000000000000000000000000000000000000000000000000000000000000f999" + // reserve pool
+		"0000000000000000000000000000000000000000000000000000000000000064" + // epoch
+		"0000000000000000000000000000000000000000000000000000000000000002" + // validators length
+		"0000000000000000000000000000000000000000000000000000000000000001" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"0000000000000000000000000000000000000000000000000000000000000002" + // managers length
+		"0000000000000000000000000000000000000000000000000000000000000011" +
+		"0000000000000000000000000000000000000000000000000000000000000012" +
+		"0000000000000000000000000000000000000000000000000000000000000002" + // fee shares length
+		"00000000000000000000000000000000000000000000000000000000000007d0" +
+		"00000000000000000000000000000000000000000000000000000000000007d0")
+
+	have, err := posa.GenesisValidatorSetBytes()
+	if err != nil {
+		t.Fatalf("failed to encode validator set: %v", err)
+	}
+	if !bytes.Equal(have, want) {
+		t.Errorf("encoding mismatch:\nhave %x\nwant %x", have, want)
+	}
+	// Invalid seeds should be rejected
+	posa.IshikariInitialManagers = posa.IshikariInitialManagers[:1]
+	if _, err := posa.GenesisValidatorSetBytes(); err == nil {
+		t.Errorf("mismatched validator set encoded")
+	}
+}
+
+func TestFirstLastFork(t *testing.T) {
+	name, block, ok := MainnetChainConfig.FirstFork()
+	if !ok || name != "homestead" || block.Sign() != 0 {
+		t.Errorf("first fork mismatch: have (%s, %v, %v), want (homestead, 0, true)", name, block, ok)
+	}
+	name, block, ok = MainnetChainConfig.LastFork()
+	if !ok || name != "ishikariPatch002" || block.Cmp(big.NewInt(11171299)) != 0 {
+		t.Errorf("last fork mismatch: have (%s, %v, %v), want (ishikariPatch002, 11171299, true)", name, block, ok)
+	}
+	// The synthetic fork must be skipped even if it's the only one
+	config := &ChainConfig{CVE_2021_39137Block: big.NewInt(10)}
+	if name, _, ok := config.FirstFork(); ok {
+		t.Errorf("first fork found in forkless config: %s", name)
+	}
+	if name, _, ok := config.LastFork(); ok {
+		t.Errorf("last fork found in forkless config: %s", name)
+	}
+}
+
+func TestDAODisabledCompatibility(t *testing.T) {
+	var (
+		stored = &ChainConfig{HomesteadBlock: big.NewInt(0), DAOForkSupport: true}
+		newcfg = &ChainConfig{HomesteadBlock: big.NewInt(0), DAOForkSupport: false}
+	)
+	if !stored.DAODisabled() {
+		t.Fatalf("DAO not disabled without a fork block")
+	}
+	if err := stored.CheckCompatible(newcfg, 1000); err != nil {
+		t.Errorf("DAO support flag change rejected with DAO disabled: %v", err)
+	}
+	// With DAO enabled, the flag still matters
+	stored.DAOForkBlock, newcfg.DAOForkBlock = big.NewInt(10), big.NewInt(10)
+	if stored.DAODisabled() {
+		t.Fatalf("DAO disabled with a fork block")
+	}
+	if err := stored.CheckCompatible(newcfg, 1000); err == nil || err.What != "DAO fork support flag" {
+		t.Errorf("DAO support flag change mismatch: have %v, want DAO fork support flag error", err)
+	}
+}
+
+func TestForkActivationBlocks(t *testing.T) {
+	blocks := MainnetChainConfig.ForkActivationBlocks()
+	for _, name := range []string{"ishikari", "ishikariPatch001", "ishikariPatch002"} {
+		if block, ok := blocks[name]; !ok || block != 11171299 {
+			t.Errorf("%s activation mismatch: have (%d, %v), want (11171299, true)", name, block, ok)
+		}
+	}
+	if _, ok := blocks["cve_2021_39137"]; ok {
+		t.Errorf("synthetic fork listed")
+	}
+	if _, ok := blocks["daoFork"]; ok {
+		t.Errorf("unscheduled fork listed")
+	}
+	if _, ok := (&ChainConfig{EWASMBlock: new(big.Int).Lsh(common.Big1, 64)}).ForkActivationBlocks()["ewasm"]; ok {
+		t.Errorf("oversized fork block listed")
+	}
+}
+
+func TestValidationErrorTypes(t *testing.T) {
+	validators := []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02")}
+	tests := []struct {
+		config *ChainConfig
+		want   error
+	}{
+		{&ChainConfig{HomesteadBlock: big.NewInt(10), EIP150Block: big.NewInt(5)}, ErrForkOrder},
+		{&ChainConfig{BerlinBlock: big.NewInt(1), YoloV3Block: big.NewInt(2)}, ErrForkOrder},
+		{&ChainConfig{POSA: &POSAConfig{Epoch: 100}}, ErrPOSAPeriod},
+		{&ChainConfig{POSA: &POSAConfig{Period: 3, Epoch: 1}}, ErrPOSAEpoch},
+		{&ChainConfig{IshikariBlock: big.NewInt(98), POSA: &POSAConfig{Period: 3, Epoch: 100,
+			IshikariInitialValidators: validators, IshikariInitialManagers: validators}}, ErrPOSAEpoch},
+		{&ChainConfig{IshikariBlock: big.NewInt(99), POSA: &POSAConfig{Period: 3, Epoch: 100}}, ErrPOSANoValidators},
+		{&ChainConfig{IshikariBlock: big.NewInt(99), POSA: &POSAConfig{Period: 3, Epoch: 100,
+			IshikariInitialValidators: validators, IshikariInitialManagers: validators[:1]}}, ErrValidatorManagerMismatch},
+	}
+	for i, tt := range tests {
+		var err error
+		if tt.config.POSA != nil {
+			err = tt.config.POSA.Validate(tt.config)
+		} else {
+			err = tt.config.CheckConfigForkOrder()
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.want)
+		}
+	}
+}
+
+func TestMergeForkOverrides(t *testing.T) {
+	config, err := MainnetChainConfig.MergeForkOverrides(map[string]*big.Int{
+		"ishikariPatch002Block": big.NewInt(12000000),
+	})
+	if err != nil {
+		t.Fatalf("failed to override patch002: %v", err)
+	}
+	if config.IshikariPatch002Block.Cmp(big.NewInt(12000000)) != 0 {
+		t.Errorf("patch002 block mismatch: have %v, want 12000000", config.IshikariPatch002Block)
+	}
+	if MainnetChainConfig.IshikariPatch002Block.Cmp(big.NewInt(11171299)) != 0 {
+		t.Errorf("base config modified")
+	}
+	// Unsetting berlin is only valid if the later forks are unset too
+	if _, err := MainnetChainConfig.MergeForkOverrides(map[string]*big.Int{"berlinBlock": nil}); !errors.Is(err, ErrForkOrder) {
+		t.Errorf("unsetting berlin below ishikari: have %v, want %v", err, ErrForkOrder)
+	}
+	config, err = MainnetChainConfig.MergeForkOverrides(map[string]*big.Int{
+		"berlinBlock":           nil,
+		"ishikariBlock":         nil,
+		"ishikariPatch001Block": nil,
+		"ishikariPatch002Block": nil,
+	})
+	if err != nil {
+		t.Fatalf("failed to unset berlin: %v", err)
+	}
+	if config.BerlinBlock != nil {
+		t.Errorf("berlin block not unset: %v", config.BerlinBlock)
+	}
+	if _, err := MainnetChainConfig.MergeForkOverrides(map[string]*big.Int{"atlantisBlock": nil}); err == nil {
+		t.Errorf("unknown fork accepted")
+	}
+}
+
+func TestMaxValidatorsSchedule(t *testing.T) {
+	posa := &POSAConfig{
+		Period:        3,
+		Epoch:         100,
+		MaxValidators: 11,
+		MaxValidatorsSchedule: []MaxValidatorsStep{
+			{Block: big.NewInt(1000), Max: 21},
+			{Block: big.NewInt(5000), Max: 29},
+		},
+	}
+	if err := posa.Validate(new(ChainConfig)); err != nil {
+		t.Fatalf("valid schedule rejected: %v", err)
+	}
+	tests := []struct {
+		num  int64
+		want uint64
+	}{
+		{0, 11}, {999, 11}, {1000, 21}, {4999, 21}, {5000, 29}, {1000000, 29},
+	}
+	for _, tt := range tests {
+		if have := posa.MaxValidatorsAt(big.NewInt(tt.num)); have != tt.want {
+			t.Errorf("cap mismatch at %d: have %d, want %d", tt.num, have, tt.want)
+		}
+	}
+	// Without a base cap, the set is unlimited until the first step
+	posa.MaxValidators = 0
+	if have := posa.MaxValidatorsAt(big.NewInt(0)); have != math.MaxUint64 {
+		t.Errorf("uncapped limit mismatch: have %d, want %d", have, uint64(math.MaxUint64))
+	}
+	// Clones must not share the schedule
+	cpy := posa.Clone()
+	cpy.MaxValidatorsSchedule[0].Block.SetInt64(1)
+	if posa.MaxValidatorsSchedule[0].Block.Int64() != 1000 {
+		t.Errorf("clone shares schedule blocks")
+	}
+	// Schedules must be strictly increasing
+	posa.MaxValidatorsSchedule[1].Block = big.NewInt(1000)
+	if err := posa.Validate(new(ChainConfig)); err == nil {
+		t.Errorf("non-increasing schedule accepted")
+	}
+}
+
+func TestEqualAtHead(t *testing.T) {
+	var (
+		local  = MainnetChainConfig.Clone()
+		remote = MainnetChainConfig.Clone()
+	)
+	// Differing in a fork neither side reached yet and in the synthetic fork
+	local.YoloV3Block, remote.YoloV3Block = big.NewInt(20000000), big.NewInt(30000000)
+	remote.CVE_2021_39137Block = nil
+	if !local.EqualAtHead(remote, 15000000) {
+		t.Errorf("configs differing only in future forks reported unequal")
+	}
+	// Once either side reaches the fork, they diverge
+	if local.EqualAtHead(remote, 25000000) {
+		t.Errorf("configs differing in a passed fork reported equal")
+	}
+	// Differing in a past fork
+	remote = MainnetChainConfig.Clone()
+	remote.IshikariPatch002Block = big.NewInt(11171300)
+	if local.EqualAtHead(remote, 15000000) {
+		t.Errorf("configs differing in a past fork reported equal")
+	}
+	// Differing in the chain or engine
+	if local.EqualAtHead(local.WithChainID(big.NewInt(9999)), 0) {
+		t.Errorf("configs differing in chain ID reported equal")
+	}
+	remote = MainnetChainConfig.Clone()
+	remote.POSA.Period = 5
+	if local.EqualAtHead(remote, 0) {
+		t.Errorf("configs differing in engine reported equal")
+	}
+}
+
+func TestForkTimeline(t *testing.T) {
+	var (
+		config = &ChainConfig{
+			HomesteadBlock: big.NewInt(0),
+			IshikariBlock:  big.NewInt(2200),
+			POSA:           &POSAConfig{Period: 3, Epoch: 100},
+		}
+		now = time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	)
+	timeline := config.ForkTimeline(1000, now)
+	want := []ForkETA{
+		{Name: "homestead", Block: 0},
+		{Name: "ishikari", Block: 2200, ETA: now.Add(time.Hour)},
+	}
+	if !reflect.DeepEqual(timeline, want) {
+		t.Errorf("timeline mismatch:\nhave %+v\nwant %+v", timeline, want)
+	}
+	// Engines without a fixed period can't estimate
+	config.POSA, config.Ethash = nil, new(EthashConfig)
+	if timeline := config.ForkTimeline(1000, now); !timeline[1].ETA.IsZero() {
+		t.Errorf("ethash fork ETA estimated: %v", timeline[1].ETA)
+	}
+}
+
+func TestEnsureIshikariInvariants(t *testing.T) {
+	config := MainnetChainConfig.Clone()
+	if err := config.EnsureIshikariInvariants(); err != nil {
+		t.Errorf("mainnet config rejected: %v", err)
+	}
+	config.IshikariBlock = nil
+	if err := config.EnsureIshikariInvariants(); err == nil || !strings.Contains(err.Error(), "without ishikariBlock") {
+		t.Errorf("patches without ishikari: have %v, want invariant error", err)
+	}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "without ishikariBlock") {
+		t.Errorf("validate: have %v, want invariant error", err)
+	}
+	config.IshikariPatch001Block, config.IshikariPatch002Block = nil, nil
+	if err := config.EnsureIshikariInvariants(); err != nil {
+		t.Errorf("config without ishikari rejected: %v", err)
+	}
+}
+
+func TestGasLimitParams(t *testing.T) {
+	config := new(ChainConfig)
+	if have := config.InitialGasLim(); have != GenesisGasLimit {
+		t.Errorf("default initial gas limit mismatch: have %d, want %d", have, GenesisGasLimit)
+	}
+	if have, want := config.TargetGas(), GenesisGasLimit/ElasticityMultiplier; have != want {
+		t.Errorf("default gas target mismatch: have %d, want %d", have, want)
+	}
+	limit, target := uint64(30000000), uint64(15000000)
+	config.InitialGasLimit, config.GasTarget = &limit, &target
+	if err := config.Validate(); err != nil {
+		t.Errorf("valid gas limits rejected: %v", err)
+	}
+	if config.InitialGasLim() != limit || config.TargetGas() != target {
+		t.Errorf("configured gas limits mismatch: have (%d, %d), want (%d, %d)", config.InitialGasLim(), config.TargetGas(), limit, target)
+	}
+	blob, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("failed to encode config: %v", err)
+	}
+	var dec ChainConfig
+	if err := json.Unmarshal(blob, &dec); err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+	if dec.InitialGasLimit == nil || *dec.InitialGasLimit != limit || dec.GasTarget == nil || *dec.GasTarget != target {
+		t.Errorf("round trip mismatch: have (%v, %v), want (%d, %d)", dec.InitialGasLimit, dec.GasTarget, limit, target)
+	}
+	target = limit + 1
+	if err := config.Validate(); err == nil {
+		t.Errorf("gas target above the limit accepted")
+	}
+}
+
+func TestPOSAValidateTransition(t *testing.T) {
+	var (
+		config = MainnetChainConfig
+		before = new(big.Int).Sub(config.IshikariBlock, common.Big1)
+		after  = new(big.Int).Add(config.IshikariBlock, common.Big1)
+	)
+	// Punishment parameters are mutable at any time
+	proposed := config.POSA.Clone()
+	proposed.JailThreshold = 48
+	if err := proposed.ValidateTransition(config.POSA, after, config.IshikariBlock); err != nil {
+		t.Errorf("jail threshold change rejected: %v", err)
+	}
+	// Block timing is fixed once Ishikari is active
+	proposed = config.POSA.Clone()
+	proposed.Period = 5
+	if err := proposed.ValidateTransition(config.POSA, after, config.IshikariBlock); !errors.Is(err, ErrPOSAPeriod) {
+		t.Errorf("period change after ishikari: have %v, want %v", err, ErrPOSAPeriod)
+	}
+	if err := proposed.ValidateTransition(config.POSA, before, config.IshikariBlock); err != nil {
+		t.Errorf("period change before ishikari rejected: %v", err)
+	}
+	// So is the validator set seeding the system contracts
+	proposed = config.POSA.Clone()
+	proposed.IshikariInitialManagers[0] = common.HexToAddress("0x01")
+	if err := proposed.ValidateTransition(config.POSA, after, config.IshikariBlock); err == nil {
+		t.Errorf("manager change after ishikari accepted")
+	}
+}
+
+func TestRequireFork(t *testing.T) {
+	config := MainnetChainConfig
+	if err := config.RequireFork("ishikari", 11171298); err == nil {
+		t.Errorf("ishikari required before activation without error")
+	}
+	if err := config.RequireFork("ishikari", 11171299); err != nil {
+		t.Errorf("ishikari required at activation: %v", err)
+	}
+	if err := config.RequireFork("ishikariBlock", 20000000); err != nil {
+		t.Errorf("ishikari required after activation: %v", err)
+	}
+	if err := config.RequireFork("ewasm", 20000000); err == nil {
+		t.Errorf("unscheduled fork required without error")
+	}
+	if err := config.RequireFork("atlantis", 0); err == nil {
+		t.Errorf("unknown fork required without error")
+	}
+}
+
+func TestTrustedCheckpointString(t *testing.T) {
+	var empty TrustedCheckpoint
+	if have, want := empty.String(), "checkpoint(section=0 head=0x000000…000000 cht=0x000000…000000 bloom=0x000000…000000 empty=true)"; have != want {
+		t.Errorf("empty checkpoint mismatch:\nhave %s\nwant %s", have, want)
+	}
+	cp := &TrustedCheckpoint{
+		SectionIndex: 42,
+		SectionHead:  common.HexToHash("0x1122330000000000000000000000000000000000000000000000000000445566"),
+		CHTRoot:      common.HexToHash("0xaabbcc0000000000000000000000000000000000000000000000000000ddeeff"),
+		BloomRoot:    common.HexToHash("0x0102030000000000000000000000000000000000000000000000000000040506"),
+	}
+	if have, want := cp.String(), "checkpoint(section=42 head=0x112233…445566 cht=0xaabbcc…ddeeff bloom=0x010203…040506 empty=false)"; have != want {
+		t.Errorf("populated checkpoint mismatch:\nhave %s\nwant %s", have, want)
+	}
+	if have := (*TrustedCheckpoint)(nil).String(); have != "checkpoint(nil)" {
+		t.Errorf("nil checkpoint mismatch: have %s", have)
+	}
+}
+
+func TestRegisterCheckpoint(t *testing.T) {
+	genesis := common.HexToHash("0xdeadbeef")
+	defer delete(TrustedCheckpoints, genesis)
+
+	checkpoint := func(section uint64) *TrustedCheckpoint {
+		return &TrustedCheckpoint{
+			SectionIndex: section,
+			SectionHead:  common.HexToHash("0x01"),
+			CHTRoot:      common.HexToHash("0x02"),
+			BloomRoot:    common.HexToHash("0x03"),
+		}
+	}
+	if _, ok := LatestCheckpoint(genesis); ok {
+		t.Fatalf("checkpoint found before registration")
+	}
+	if err := RegisterCheckpoint(genesis, new(TrustedCheckpoint)); err == nil {
+		t.Errorf("empty checkpoint registered")
+	}
+	if err := RegisterCheckpoint(genesis, checkpoint(10)); err != nil {
+		t.Fatalf("failed to register checkpoint: %v", err)
+	}
+	if err := RegisterCheckpoint(genesis, checkpoint(9)); err == nil {
+		t.Errorf("stale checkpoint registered")
+	}
+	if err := RegisterCheckpoint(genesis, checkpoint(11)); err != nil {
+		t.Fatalf("failed to register newer checkpoint: %v", err)
+	}
+	if cp, ok := LatestCheckpoint(genesis); !ok || cp.SectionIndex != 11 {
+		t.Errorf("latest checkpoint mismatch: have (%v, %v), want section 11", cp, ok)
+	}
+}
+
+func TestIsLondon(t *testing.T) {
+	config := &ChainConfig{BerlinBlock: big.NewInt(10)}
+	for _, tt := range []struct {
+		num  int64
+		want bool
+	}{{9, false}, {10, true}, {11, true}} {
+		if have := config.IsLondon(big.NewInt(tt.num)); have != tt.want {
+			t.Errorf("fallback london at %d: have %v, want %v", tt.num, have, tt.want)
+		}
+	}
+	config.LondonBlock = big.NewInt(20)
+	for _, tt := range []struct {
+		num  int64
+		want bool
+	}{{10, false}, {19, false}, {20, true}} {
+		if have := config.IsLondon(big.NewInt(tt.num)); have != tt.want {
+			t.Errorf("explicit london at %d: have %v, want %v", tt.num, have, tt.want)
+		}
+	}
+	// London is scheduled after the Ishikari forks
+	config = MainnetChainConfig.Clone()
+	config.LondonBlock = big.NewInt(20000000)
+	if err := config.CheckConfigForkOrder(); err != nil {
+		t.Errorf("london after ishikari rejected: %v", err)
+	}
+	config.LondonBlock = big.NewInt(11171298)
+	if err := config.CheckConfigForkOrder(); !errors.Is(err, ErrForkOrder) {
+		t.Errorf("london before ishikari: have %v, want %v", err, ErrForkOrder)
+	}
+}
+
+func TestPOSAConfigEqual(t *testing.T) {
+	base := MainnetChainConfig.POSA
+	if !base.Equal(base.Clone()) {
+		t.Errorf("clone not equal")
+	}
+	reordered := base.Clone()
+	reordered.IshikariInitialValidators[0], reordered.IshikariInitialValidators[1] = reordered.IshikariInitialValidators[1], reordered.IshikariInitialValidators[0]
+	if base.Equal(reordered) {
+		t.Errorf("reordered validators reported equal")
+	}
+	var (
+		nilManagers   = &POSAConfig{Period: 3, Epoch: 100}
+		emptyManagers = &POSAConfig{Period: 3, Epoch: 100, IshikariInitialManagers: []common.Address{}}
+	)
+	if !nilManagers.Equal(emptyManagers) {
+		t.Errorf("nil and empty managers reported unequal")
+	}
+	changed := base.Clone()
+	changed.JailThreshold = 1
+	if base.Equal(changed) {
+		t.Errorf("configs differing in jail threshold reported equal")
+	}
+	if base.Equal(nil) || !(*POSAConfig)(nil).Equal(nil) {
+		t.Errorf("nil config comparison mismatch")
+	}
+}
+
+func TestUnreachableForks(t *testing.T) {
+	if have, want := MainnetChainConfig.UnreachableForks(), []string{"ishikari", "ishikariPatch001"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("mainnet unreachable forks mismatch: have %v, want %v", have, want)
+	}
+	if have := TestnetChainConfig.UnreachableForks(); len(have) != 0 {
+		t.Errorf("staggered forks reported unreachable: %v", have)
+	}
+}
+
+func TestActiveEIPs(t *testing.T) {
+	contains := func(eips []int, eip int) bool {
+		for _, have := range eips {
+			if have == eip {
+				return true
+			}
+		}
+		return false
+	}
+	head := MainnetChainConfig.ActiveEIPs(big.NewInt(20000000))
+	for _, eip := range []int{150, 155, 2200, 2565, 2718, 2929, 2930} {
+		if !contains(head, eip) {
+			t.Errorf("EIP-%d missing at mainnet head: %v", eip, head)
+		}
+	}
+	if contains(head, 1283) {
+		t.Errorf("EIP-1283 active after Petersburg")
+	}
+	config := &ChainConfig{IstanbulBlock: big.NewInt(0), BerlinBlock: big.NewInt(100)}
+	before := config.ActiveEIPs(big.NewInt(99))
+	for _, eip := range []int{2565, 2718, 2929, 2930} {
+		if contains(before, eip) {
+			t.Errorf("EIP-%d active before Berlin: %v", eip, before)
+		}
+	}
+	if !contains(before, 2200) {
+		t.Errorf("EIP-2200 missing after Istanbul: %v", before)
+	}
+}
+
+func TestEstimateForkTime(t *testing.T) {
+	var (
+		ishikari = time.Date(2021, 11, 15, 8, 0, 0, 0, time.UTC)
+		ref      = BlockTimeRef{Block: 11171299 + 1200, Time: ishikari.Add(time.Hour)}
+	)
+	if have, ok := MainnetChainConfig.EstimateForkTime("ishikari", ref); !ok || !have.Equal(ishikari) {
+		t.Errorf("ishikari time mismatch: have (%v, %v), want (%v, true)", have, ok, ishikari)
+	}
+	ref = BlockTimeRef{Block: 11171299 - 1200, Time: ishikari.Add(-time.Hour)}
+	if have, ok := MainnetChainConfig.EstimateForkTime("ishikariBlock", ref); !ok || !have.Equal(ishikari) {
+		t.Errorf("forward ishikari time mismatch: have (%v, %v), want (%v, true)", have, ok, ishikari)
+	}
+	if _, ok := MainnetChainConfig.EstimateForkTime("ewasm", ref); ok {
+		t.Errorf("unscheduled fork time estimated")
+	}
+	if _, ok := AllEthashProtocolChanges.EstimateForkTime("berlin", ref); ok {
+		t.Errorf("fork time estimated without block period")
+	}
+}
+
+func TestValidateEIP150Hash(t *testing.T) {
+	// Genesis EIP150 without a hash is benign
+	if err := MainnetChainConfig.ValidateStrict(); err != nil {
+		t.Errorf("mainnet config rejected: %v", err)
+	}
+	config := &ChainConfig{ChainID: big.NewInt(1), HomesteadBlock: big.NewInt(0), EIP150Block: big.NewInt(100)}
+	report := config.ValidateWithReport()
+	if len(report.Errors) != 0 || len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], "eip150Hash") {
+		t.Errorf("missing eip150 hash: have errors %v warnings %v, want a single warning", report.Errors, report.Warnings)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("missing eip150 hash rejected: %v", err)
+	}
+	if err := config.ValidateStrict(); err == nil {
+		t.Errorf("missing eip150 hash accepted in strict mode")
+	}
+	config.EIP150Hash = common.HexToHash("0x2086799aeebeae135c246c65021c82b4e15a2c451340993aacfd2751886514f0")
+	if err := config.ValidateStrict(); err != nil {
+		t.Errorf("eip150 with hash rejected in strict mode: %v", err)
+	}
+}
+
+func TestMinimumSyncMode(t *testing.T) {
+	for _, num := range []int64{0, 2509228, 11171298, 11171299, 20000000} {
+		if have := MainnetChainConfig.MinimumSyncMode(big.NewInt(num)); have != SyncModeFull {
+			t.Errorf("sync mode mismatch at %d: have %s, want %s", num, have, SyncModeFull)
+		}
+	}
+	// Forks gated on archive nodes raise the requirement once active
+	forkSyncModes["ishikariPatch002"] = SyncModeArchive
+	defer delete(forkSyncModes, "ishikariPatch002")
+
+	if have := MainnetChainConfig.MinimumSyncMode(big.NewInt(11171298)); have != SyncModeFull {
+		t.Errorf("sync mode mismatch before gated fork: have %s, want %s", have, SyncModeFull)
+	}
+	if have := MainnetChainConfig.MinimumSyncMode(big.NewInt(11171299)); have != SyncModeArchive {
+		t.Errorf("sync mode mismatch after gated fork: have %s, want %s", have, SyncModeArchive)
+	}
+}
+
+func TestAllCheckpointsSorted(t *testing.T) {
+	var (
+		low  = common.HexToHash("0x01")
+		high = common.HexToHash("0xff00000000000000000000000000000000000000000000000000000000000000")
+		cp   = &TrustedCheckpoint{SectionHead: common.HexToHash("0x01"), CHTRoot: common.HexToHash("0x02"), BloomRoot: common.HexToHash("0x03")}
+	)
+	defer delete(TrustedCheckpoints, low)
+	defer delete(TrustedCheckpoints, high)
+	defer delete(CheckpointOracles, low)
+	defer delete(CheckpointOracles, high)
+
+	for _, genesis := range []common.Hash{high, low} {
+		if err := RegisterCheckpoint(genesis, cp); err != nil {
+			t.Fatalf("failed to register checkpoint: %v", err)
+		}
+		CheckpointOracles[genesis] = &CheckpointOracleConfig{Threshold: 1}
+	}
+	checkpoints := AllTrustedCheckpoints()
+	if len(checkpoints) != 2 || checkpoints[0].Genesis != low || checkpoints[1].Genesis != high {
+		t.Errorf("checkpoints not sorted: %v", checkpoints)
+	}
+	oracles := AllCheckpointOracles()
+	if len(oracles) != 2 || oracles[0].Genesis != low || oracles[1].Genesis != high {
+		t.Errorf("oracles not sorted: %v", oracles)
+	}
+}
+
+func TestPOSAIsCheckpoint(t *testing.T) {
+	config := &POSAConfig{Period: 3, Epoch: 100}
+	for num, want := range map[int64]bool{0: true, 50: false, 99: false, 100: true, 200: true} {
+		if have := config.IsCheckpoint(big.NewInt(num)); have != want {
+			t.Errorf("epoch fallback, block %d: have %v, want %v", num, have, want)
+		}
+	}
+	config.CheckpointInterval = 50
+	for num, want := range map[int64]bool{0: true, 50: true, 75: false, 100: true, 150: true} {
+		if have := config.IsCheckpoint(big.NewInt(num)); have != want {
+			t.Errorf("explicit interval, block %d: have %v, want %v", num, have, want)
+		}
+	}
+	if err := config.Validate(&ChainConfig{}); err != nil {
+		t.Errorf("aligned checkpoint interval rejected: %v", err)
+	}
+	config.CheckpointInterval = 30
+	if err := config.Validate(&ChainConfig{}); !errors.Is(err, ErrPOSAEpoch) {
+		t.Errorf("misaligned checkpoint interval: have %v, want %v", err, ErrPOSAEpoch)
+	}
+}
+
+func TestBuiltinConfigsValid(t *testing.T) {
+	for name, config := range map[string]*ChainConfig{"mainnet": MainnetChainConfig, "testnet": TestnetChainConfig} {
+		if err := config.Validate(); err != nil {
+			t.Errorf("%s config invalid: %v", name, err)
+		}
+	}
+}
+
+func TestMustValidatePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("invalid config didn't panic")
+		}
+	}()
+	MainnetChainConfig.WithFork("ishikari", nil).WithFork("homestead", big.NewInt(100)).MustValidate()
+}
+
+func TestRulesSupportsTxType(t *testing.T) {
+	config := &ChainConfig{BerlinBlock: big.NewInt(10)}
+	tests := []struct {
+		num  int64
+		typ  uint8
+		want bool
+	}{
+		{9, 0, true}, {9, 1, false}, {9, 2, false},
+		{10, 0, true}, {10, 1, true}, {10, 2, false},
+		{10, 3, false},
+	}
+	for _, tt := range tests {
+		rules := config.Rules(big.NewInt(tt.num))
+		if have := rules.SupportsTxType(tt.typ); have != tt.want {
+			t.Errorf("block %d, type %d: have %v, want %v", tt.num, tt.typ, have, tt.want)
+		}
+	}
+}
+
+func TestForkProgress(t *testing.T) {
+	config := &ChainConfig{BerlinBlock: big.NewInt(0), IshikariBlock: big.NewInt(1000), IshikariPatch001Block: big.NewInt(1000), EWASMBlock: big.NewInt(2000)}
+	tests := []struct {
+		head     uint64
+		name     string
+		progress float64
+		ok       bool
+	}{
+		{0, "ishikari", 0, true},
+		{500, "ishikari", 0.5, true},
+		{999, "ishikari", 0.999, true},
+		{1000, "ewasm", 0, true},
+		{1250, "ewasm", 0.25, true},
+		{2000, "", 0, false},
+	}
+	for _, tt := range tests {
+		name, progress, ok := config.ForkProgress(tt.head)
+		if name != tt.name || progress != tt.progress || ok != tt.ok {
+			t.Errorf("head %d: have (%q, %v, %v), want (%q, %v, %v)", tt.head, name, progress, ok, tt.name, tt.progress, tt.ok)
+		}
+	}
+}
+
+func TestNilConfigRules(t *testing.T) {
+	var config *ChainConfig
+	if !config.IsNil() {
+		t.Fatalf("nil config not reported as nil")
+	}
+	if config.IsKCCIshikari(big.NewInt(0)) {
+		t.Errorf("nil config reports Ishikari active")
+	}
+	if config.IsCVE202139137(big.NewInt(0)) || config.IsBerlin(big.NewInt(0)) {
+		t.Errorf("nil config reports forks active")
+	}
+	rules := config.Rules(big.NewInt(0))
+	if rules.ChainID == nil || rules.ChainID.Sign() != 0 || rules.IsHomestead || rules.IsIshikari {
+		t.Errorf("nil config rules not zero: %+v", rules)
+	}
+	if MainnetChainConfig.IsNil() {
+		t.Errorf("mainnet config reported as nil")
+	}
+}
+
+func TestIndexOfValidator(t *testing.T) {
+	validators := MainnetChainConfig.POSA.IshikariInitialValidators
+	for want, addr := range validators {
+		if have, ok := MainnetChainConfig.POSA.IndexOfValidator(addr); !ok || have != want {
+			t.Errorf("validator %x: have (%d, %v), want (%d, true)", addr, have, ok, want)
+		}
+	}
+	if have, ok := MainnetChainConfig.POSA.IndexOfValidator(common.Address{0xff}); ok || have != -1 {
+		t.Errorf("unknown validator: have (%d, %v), want (-1, false)", have, ok)
+	}
+}
+
+func TestChainConfigMerge(t *testing.T) {
+	base := MainnetChainConfig.Clone()
+	base.POSA = nil
+
+	merged, err := base.Merge(MainnetChainConfig.POSA)
+	if err != nil {
+		t.Fatalf("failed to merge engine: %v", err)
+	}
+	if !merged.Equal(MainnetChainConfig) {
+		t.Errorf("merged config mismatch: have %v, want %v", merged, MainnetChainConfig)
+	}
+	if base.POSA != nil {
+		t.Errorf("base config modified")
+	}
+	if merged.POSA == MainnetChainConfig.POSA {
+		t.Errorf("merged engine config not copied")
+	}
+	if _, err := merged.Merge(new(EthashConfig)); err == nil {
+		t.Errorf("second engine merged")
+	}
+	if _, err := base.Merge(struct{}{}); err == nil {
+		t.Errorf("unknown engine type merged")
+	}
+	if _, err := base.Merge((*POSAConfig)(nil)); err == nil {
+		t.Errorf("nil engine merged")
+	}
+	if _, err := base.Merge(&POSAConfig{Period: 3}); err == nil {
+		t.Errorf("invalid engine merged")
+	}
+}
+
+func TestValidTxChainID(t *testing.T) {
+	config := &ChainConfig{ChainID: big.NewInt(321), EIP155Block: big.NewInt(10)}
+	tests := []struct {
+		num     int64
+		chainID *big.Int
+		want    bool
+	}{
+		{9, big.NewInt(321), true},
+		{9, big.NewInt(1), true},
+		{9, nil, true},
+		{10, big.NewInt(321), true},
+		{10, big.NewInt(1), false},
+		{10, nil, false},
+	}
+	for _, tt := range tests {
+		if have := config.ValidTxChainID(tt.chainID, big.NewInt(tt.num)); have != tt.want {
+			t.Errorf("block %d, chain ID %v: have %v, want %v", tt.num, tt.chainID, have, tt.want)
+		}
+		if have, want := config.Rules(big.NewInt(tt.num)).RequiresChainID(), tt.num >= 10; have != want {
+			t.Errorf("block %d: replay protection required %v, want %v", tt.num, have, want)
+		}
+	}
+}
+
+func TestForkName(t *testing.T) {
+	tests := []struct {
+		config *ChainConfig
+		num    int64
+		want   string
+	}{
+		{MainnetChainConfig, 0, "berlin"},
+		{MainnetChainConfig, 2509229, "berlin"},
+		{MainnetChainConfig, 11171298, "berlin"},
+		{MainnetChainConfig, 11171299, "ishikariPatch002"},
+		{MainnetChainConfig, 20000000, "ishikariPatch002"},
+		{TestnetChainConfig, 11321699, "ishikari"},
+		{TestnetChainConfig, 12153317, "ishikariPatch001"},
+		{&ChainConfig{HomesteadBlock: big.NewInt(5)}, 4, "genesis"},
+		{&ChainConfig{HomesteadBlock: big.NewInt(5)}, 5, "homestead"},
+	}
+	for _, tt := range tests {
+		if have := tt.config.ForkName(big.NewInt(tt.num)); have != tt.want {
+			t.Errorf("chain %v, block %d: have %q, want %q", tt.config.ChainID, tt.num, have, tt.want)
+		}
+	}
+}
+
+func TestDisabledOpcodes(t *testing.T) {
+	config := TestnetChainConfig.Clone()
+	config.DisabledOpcodes = map[string][]string{"ishikari": {"SELFDESTRUCT"}}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+	if disabled := config.DisabledOpcodesAt(big.NewInt(11321698)); disabled["SELFDESTRUCT"] {
+		t.Errorf("SELFDESTRUCT disabled before Ishikari")
+	}
+	if disabled := config.DisabledOpcodesAt(big.NewInt(11321699)); !disabled["SELFDESTRUCT"] || len(disabled) != 1 {
+		t.Errorf("disabled opcodes mismatch at Ishikari: %v", disabled)
+	}
+	if config.Equal(TestnetChainConfig) || !config.Equal(config.Clone()) {
+		t.Errorf("disabled opcodes not compared")
+	}
+	config.DisabledOpcodes["ishikari"] = []string{"SELFDESTRUCTX"}
+	if err := config.Validate(); err == nil {
+		t.Errorf("unknown opcode accepted")
+	}
+	config.DisabledOpcodes = map[string][]string{"frontier2": {"SELFDESTRUCT"}}
+	if err := config.Validate(); err == nil {
+		t.Errorf("unknown fork accepted")
+	}
+}
+
+func TestConfigOverlaps(t *testing.T) {
+	twin := MainnetChainConfig.Clone()
+	twin.POSA = nil
+	if !MainnetChainConfig.Overlaps(twin, 20000000) {
+		t.Errorf("identical schedules don't overlap")
+	}
+	if MainnetChainConfig.Overlaps(twin.WithChainID(big.NewInt(9999)), 20000000) {
+		t.Errorf("differing chain IDs overlap")
+	}
+	shifted := twin.WithFork("ishikari", big.NewInt(11171300)).WithFork("ishikariPatch001", big.NewInt(11171300)).WithFork("ishikariPatch002", big.NewInt(11171300))
+	if !MainnetChainConfig.Overlaps(shifted, 11171298) {
+		t.Errorf("schedules identical up to head don't overlap")
+	}
+	if MainnetChainConfig.Overlaps(shifted, 20000000) {
+		t.Errorf("differing schedules overlap")
+	}
+}
+
+func TestIshikariActivation(t *testing.T) {
+	tests := []struct {
+		config        *ChainConfig
+		num           int64
+		full, partial bool
+	}{
+		{TestnetChainConfig, 11321698, false, false},
+		{TestnetChainConfig, 11321699, false, true},
+		{TestnetChainConfig, 12153317, false, true},
+		{TestnetChainConfig, 12162885, false, true},
+		{TestnetChainConfig, 12162886, true, false},
+		{MainnetChainConfig, 11171298, false, false},
+		{MainnetChainConfig, 11171299, true, false},
+	}
+	for _, tt := range tests {
+		num := big.NewInt(tt.num)
+		if have := tt.config.IshikariFullyActivated(num); have != tt.full {
+			t.Errorf("chain %v, block %d: fully activated %v, want %v", tt.config.ChainID, tt.num, have, tt.full)
+		}
+		if have := tt.config.IshikariPartiallyActivated(num); have != tt.partial {
+			t.Errorf("chain %v, block %d: partially activated %v, want %v", tt.config.ChainID, tt.num, have, tt.partial)
+		}
+	}
+}
+
+func TestConfigCompatErrorWrapping(t *testing.T) {
+	stored := MainnetChainConfig
+	updated := stored.WithFork("ishikari", big.NewInt(11171300))
+	compatErr := stored.CheckCompatible(updated, 20000000)
+	if compatErr == nil {
+		t.Fatalf("incompatible config accepted")
+	}
+	err := fmt.Errorf("failed to set up genesis: %w", compatErr)
+	if !errors.Is(err, ErrConfigIncompatible) {
+		t.Errorf("wrapped error doesn't match ErrConfigIncompatible")
+	}
+	var target *ConfigCompatError
+	if !errors.As(err, &target) || target != compatErr {
+		t.Errorf("compat error not extracted: have %v, want %v", target, compatErr)
+	}
+	if errors.Is(fmt.Errorf("wrapped: %w", ErrForkOrder), ErrConfigIncompatible) {
+		t.Errorf("unrelated error matches ErrConfigIncompatible")
+	}
+}
+
+func TestSystemContract(t *testing.T) {
+	if addr, ok := MainnetChainConfig.SystemContract("validators"); !ok || addr != DefaultValidatorContractAddress {
+		t.Errorf("validators contract mismatch: have (%x, %v), want (%x, true)", addr, ok, DefaultValidatorContractAddress)
+	}
+	if addr, ok := MainnetChainConfig.SystemContract("punish"); !ok || addr != DefaultPunishContractAddress {
+		t.Errorf("punish contract mismatch: have (%x, %v), want (%x, true)", addr, ok, DefaultPunishContractAddress)
+	}
+	if _, ok := MainnetChainConfig.SystemContract("proposal"); ok {
+		t.Errorf("unknown system contract resolved")
+	}
+	if _, ok := AllEthashProtocolChanges.SystemContract("validators"); ok {
+		t.Errorf("system contract resolved on non-POSA chain")
+	}
+	config := MainnetChainConfig.Clone()
+	override := common.HexToAddress("0x000000000000000000000000000000000000f999")
+	config.POSA.ValidatorContractAddress = &override
+	if addr, _ := config.SystemContract("validators"); addr != override {
+		t.Errorf("override ignored: have %x, want %x", addr, override)
+	}
+	if addr, _ := config.SystemContract("punish"); addr != DefaultPunishContractAddress {
+		t.Errorf("punish contract mismatch: have %x, want %x", addr, DefaultPunishContractAddress)
+	}
+	if config.POSA.Equal(MainnetChainConfig.POSA) {
+		t.Errorf("override not compared")
+	}
+	if cpy := config.POSA.Clone(); cpy.ValidatorContractAddress == config.POSA.ValidatorContractAddress {
+		t.Errorf("override not deep copied")
+	}
+}
+
+func TestValidateAgainstGenesisAlloc(t *testing.T) {
+	config := MainnetChainConfig.POSA
+	validators := config.IshikariInitialValidators
+
+	reversed := make([]common.Address, len(validators))
+	for i, addr := range validators {
+		reversed[len(validators)-1-i] = addr
+	}
+	if err := config.ValidateAgainstGenesisAlloc(reversed); err != nil {
+		t.Errorf("matching sets rejected: %v", err)
+	}
+	if err := config.ValidateAgainstGenesisAlloc(validators[1:]); err == nil {
+		t.Errorf("missing validator accepted")
+	}
+	extra := append(append([]common.Address{}, validators...), common.Address{0xff})
+	if err := config.ValidateAgainstGenesisAlloc(extra); err == nil {
+		t.Errorf("extra validator accepted")
+	}
+}
+
+func TestIsGenesisFork(t *testing.T) {
+	for _, name := range []string{"homestead", "eip150", "petersburg", "muirGlacier", "berlin", "berlinBlock"} {
+		if !MainnetChainConfig.IsGenesisFork(name) {
+			t.Errorf("%s not a genesis fork on mainnet", name)
+		}
+	}
+	for _, name := range []string{"daoFork", "ishikari", "ishikariPatch002", "cve_2021_39137", "ewasm", "frontier2"} {
+		if MainnetChainConfig.IsGenesisFork(name) {
+			t.Errorf("%s is a genesis fork on mainnet", name)
+		}
+	}
+}
+
+func TestParseAddressList(t *testing.T) {
+	input := "0x4788aE8d8A2b2b4b2a8c3E9Fb1cF1c1fC05f3c1D, 0x8Cc9b8e7Ad4d7D82Ea0cC43e23E0d1bF8A4B5F60\n" +
+		"\t0xa2DbA5Bd5C49B5f8D2E3C4b5b2A8D8D1e7A9b4f1 \r\n\n" +
+		"0x000000000000000000000000000000000000f333,,  \n"
+	want := []common.Address{
+		common.HexToAddress("0x4788aE8d8A2b2b4b2a8c3E9Fb1cF1c1fC05f3c1D"),
+		common.HexToAddress("0x8Cc9b8e7Ad4d7D82Ea0cC43e23E0d1bF8A4B5F60"),
+		common.HexToAddress("0xa2DbA5Bd5C49B5f8D2E3C4b5b2A8D8D1e7A9b4f1"),
+		common.HexToAddress("0x000000000000000000000000000000000000f333"),
+	}
+	have, err := ParseAddressList(input)
+	if err != nil {
+		t.Fatalf("failed to parse address list: %v", err)
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("address list mismatch:\nhave %v\nwant %v", have, want)
+	}
+	_, err = ParseAddressList("0x4788aE8d8A2b2b4b2a8c3E9Fb1cF1c1fC05f3c1D\n0x8Cc9b8e7Ad4d7D82Ea0cC43e23E0d1bF8A4B5F6")
+	if err == nil || !strings.Contains(err.Error(), "line 2") || !strings.Contains(err.Error(), "index 1") {
+		t.Errorf("bad address error mismatch: %v", err)
+	}
+}
+
+func TestIsPostMerge(t *testing.T) {
+	// Without a terminal total difficulty the merge fields are inert
+	config := MainnetChainConfig.WithFork("mergeNetsplit", big.NewInt(20000000))
+	if config.IsPostMerge(big.NewInt(30000000), new(big.Int).Lsh(common.Big1, 128)) {
+		t.Errorf("merge active without terminal total difficulty")
+	}
+	if MainnetChainConfig.IsPostMerge(big.NewInt(30000000), big.NewInt(1)) {
+		t.Errorf("merge active on mainnet")
+	}
+	config.TerminalTotalDifficulty = big.NewInt(1000)
+	if err := config.Validate(); err != nil {
+		t.Fatalf("merge config rejected: %v", err)
+	}
+	tests := []struct {
+		num, td int64
+		want    bool
+	}{
+		{100, 999, false},
+		{100, 1000, true},
+		{19999999, 999, false},
+		{20000000, 999, true},
+	}
+	for _, tt := range tests {
+		if have := config.IsPostMerge(big.NewInt(tt.num), big.NewInt(tt.td)); have != tt.want {
+			t.Errorf("block %d, td %d: have %v, want %v", tt.num, tt.td, have, tt.want)
+		}
+	}
+	config.TerminalTotalDifficulty = big.NewInt(-1)
+	if err := config.Validate(); err == nil {
+		t.Errorf("negative terminal total difficulty accepted")
+	}
+}
+
+func TestCompareForkSchedules(t *testing.T) {
+	diffs := make(map[string]ForkComparison)
+	comparisons := MainnetChainConfig.CompareForkSchedules(TestnetChainConfig)
+	for _, cmp := range comparisons {
+		if !cmp.Same {
+			diffs[cmp.Name] = cmp
+		}
+	}
+	if len(comparisons) != len(MainnetChainConfig.forks()) || comparisons[0].Name != "homestead" {
+		t.Errorf("comparison doesn't cover all forks in order: %v", comparisons)
+	}
+	if len(diffs) != 4 {
+		t.Errorf("differing fork count mismatch: have %d, want 4: %v", len(diffs), diffs)
+	}
+	if cmp := diffs["ishikari"]; cmp.Self.Cmp(big.NewInt(11171299)) != 0 || cmp.Other.Cmp(big.NewInt(11321699)) != 0 {
+		t.Errorf("ishikari comparison mismatch: %+v", cmp)
+	}
+	for _, name := range []string{"cve_2021_39137", "ishikariPatch001", "ishikariPatch002"} {
+		if _, ok := diffs[name]; !ok {
+			t.Errorf("%s not reported as differing", name)
+		}
+	}
+}
+
+func TestPOSAEpochDuration(t *testing.T) {
+	if report := MainnetChainConfig.ValidateWithReport(); report.Err() != nil || len(report.Warnings) != 0 {
+		t.Errorf("mainnet epoch duration rejected: %v %v", report.Errors, report.Warnings)
+	}
+	config := &ChainConfig{ChainID: big.NewInt(1), POSA: &POSAConfig{Period: 3, Epoch: math.MaxUint64 / 2}}
+	if err := config.POSA.Validate(config); !errors.Is(err, ErrPOSAEpoch) {
+		t.Errorf("overflowing epoch: have %v, want %v", err, ErrPOSAEpoch)
+	}
+	config.POSA.Epoch = 28800 * 2 // two days of 3 second blocks
+	report := config.ValidateWithReport()
+	if err := report.Err(); err != nil {
+		t.Fatalf("long epoch rejected: %v", err)
+	}
+	if len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], "posa epoch lasts 48h0m0s") {
+		t.Errorf("long epoch warning mismatch: %v", report.Warnings)
+	}
+}
+
+func TestRulesPrecompileSet(t *testing.T) {
+	var (
+		config  = &ChainConfig{ByzantiumBlock: big.NewInt(10), IstanbulBlock: big.NewInt(20)}
+		blake2f = common.BytesToAddress([]byte{9})
+		modexp  = common.BytesToAddress([]byte{5})
+	)
+	if set := config.Rules(big.NewInt(9)).PrecompileSet(); len(set) != 4 || set[modexp] {
+		t.Errorf("pre-Byzantium precompiles mismatch: %v", set)
+	}
+	if set := config.Rules(big.NewInt(19)).PrecompileSet(); len(set) != 8 || set[blake2f] {
+		t.Errorf("pre-Istanbul precompiles mismatch: %v", set)
+	}
+	if set := config.Rules(big.NewInt(20)).PrecompileSet(); len(set) != 9 || !set[blake2f] {
+		t.Errorf("post-Istanbul precompiles mismatch: %v", set)
+	}
+	if set := MainnetChainConfig.Rules(big.NewInt(0)).PrecompileSet(); !set[blake2f] {
+		t.Errorf("blake2f missing on mainnet")
+	}
+}
+
+func TestDefaultCheckpointOracle(t *testing.T) {
+	oracle, ok := DefaultCheckpointOracle(TestnetGenesisHash)
+	if !ok || oracle != TestnetCheckpointOracle {
+		t.Errorf("testnet oracle mismatch: have (%v, %v), want (%v, true)", oracle, ok, TestnetCheckpointOracle)
+	}
+	if _, ok := CheckpointOracles[TestnetGenesisHash]; ok {
+		t.Errorf("placeholder testnet oracle registered")
+	}
+	if _, ok := DefaultCheckpointOracle(common.Hash{0x01}); ok {
+		t.Errorf("oracle found for unknown genesis")
+	}
+}
+
+func TestSetEngine(t *testing.T) {
+	config := AllCliqueProtocolChanges.Clone()
+	if err := config.SetEngine(MainnetChainConfig.POSA); err != nil {
+		t.Fatalf("failed to switch engine: %v", err)
+	}
+	if config.Clique != nil || !config.POSA.Equal(MainnetChainConfig.POSA) || config.POSA == MainnetChainConfig.POSA {
+		t.Errorf("engine not switched: %v", config)
+	}
+	if err := config.SetEngine("posa"); err == nil {
+		t.Errorf("non-engine argument accepted")
+	}
+	if err := config.SetEngine((*CliqueConfig)(nil)); err == nil {
+		t.Errorf("nil engine accepted")
+	}
+	if config.POSA == nil {
+		t.Errorf("failed engine switch modified the config")
+	}
+	config.Ethash = new(EthashConfig)
+	if err := config.RequiresConsensusEngine(); err == nil {
+		t.Errorf("multiple engines accepted")
+	}
+}
+
+func TestForkDistance(t *testing.T) {
+	config := MainnetChainConfig.WithFork("ewasm", new(big.Int).Lsh(common.Big1, 70))
+	tests := []struct {
+		name string
+		head uint64
+		want int64
+		ok   bool
+	}{
+		{"ishikari", 11171000, 299, true},
+		{"ishikari", 11171299, 0, true},
+		{"ishikari", 11172299, -1000, true},
+		{"berlin", math.MaxUint64, math.MinInt64, true},
+		{"ewasm", 0, math.MaxInt64, true},
+		{"yoloV3", 0, 0, false},
+		{"frontier2", 0, 0, false},
+	}
+	for _, tt := range tests {
+		if have, ok := config.ForkDistance(tt.name, tt.head); have != tt.want || ok != tt.ok {
+			t.Errorf("%s at %d: have (%d, %v), want (%d, %v)", tt.name, tt.head, have, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDisableFork(t *testing.T) {
+	config, disabled, err := MainnetChainConfig.DisableFork("berlinBlock")
+	if err != nil {
+		t.Fatalf("failed to disable fork: %v", err)
+	}
+	if want := []string{"berlin", "ishikari", "ishikariPatch001", "ishikariPatch002"}; !reflect.DeepEqual(disabled, want) {
+		t.Errorf("disabled forks mismatch: have %v, want %v", disabled, want)
+	}
+	if config.BerlinBlock != nil || config.IshikariBlock != nil || config.IshikariPatch002Block != nil {
+		t.Errorf("forks not disabled: %v", config)
+	}
+	if config.IstanbulBlock == nil || config.CVE_2021_39137Block == nil || MainnetChainConfig.BerlinBlock == nil {
+		t.Errorf("unrelated forks disabled: %v", config)
+	}
+	// Optional forks don't cascade
+	config, disabled, err = MainnetChainConfig.DisableFork("muirGlacier")
+	if err != nil {
+		t.Fatalf("failed to disable fork: %v", err)
+	}
+	if want := []string{"muirGlacier"}; !reflect.DeepEqual(disabled, want) || config.BerlinBlock == nil {
+		t.Errorf("optional fork disable cascaded: %v", disabled)
+	}
+	if _, _, err := MainnetChainConfig.DisableFork("frontier2"); err == nil {
+		t.Errorf("unknown fork disabled")
+	}
+	// Settings tied to the disabled forks must go too, keeping the config valid
+	base := MainnetChainConfig.Clone()
+	base.MinClientVersions = map[string]string{"istanbul": "v1.0.4", "ishikari": "v1.2.0"}
+	base.DisabledOpcodes = map[string][]string{"ishikariPatch002Block": {"SELFDESTRUCT"}}
+
+	config, _, err = base.DisableFork("berlin")
+	if err != nil {
+		t.Fatalf("failed to disable fork: %v", err)
+	}
+	if want := map[string]string{"istanbul": "v1.0.4"}; !reflect.DeepEqual(config.MinClientVersions, want) {
+		t.Errorf("client versions mismatch: have %v, want %v", config.MinClientVersions, want)
+	}
+	if len(config.DisabledOpcodes) != 0 {
+		t.Errorf("disabled opcodes of disabled forks retained: %v", config.DisabledOpcodes)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("config with disabled fork invalid: %v", err)
+	}
+	if len(base.MinClientVersions) != 2 || len(base.DisabledOpcodes) != 1 {
+		t.Errorf("original config modified")
+	}
+}
+
+func TestPOSAProposerAt(t *testing.T) {
+	var (
+		config = MainnetChainConfig.POSA
+		count  = int64(len(config.IshikariInitialValidators))
+		seen   = make(map[common.Address]bool)
+	)
+	for num := int64(0); num < count; num++ {
+		proposer, ok := config.ProposerAt(big.NewInt(num))
+		if !ok {
+			t.Fatalf("no proposer at %d", num)
+		}
+		if seen[proposer] {
+			t.Errorf("proposer %x repeated within a cycle at %d", proposer, num)
+		}
+		seen[proposer] = true
+
+		if next, _ := config.ProposerAt(big.NewInt(num + count)); next != proposer {
+			t.Errorf("proposer at %d not cycling: have %x, want %x", num+count, next, proposer)
+		}
+		if num > 0 {
+			prev, _ := config.ProposerAt(big.NewInt(num - 1))
+			if bytes.Compare(prev[:], proposer[:]) >= 0 {
+				t.Errorf("proposers not seated by address at %d: %x before %x", num, prev, proposer)
+			}
+		}
+	}
+	if _, ok := new(POSAConfig).ProposerAt(big.NewInt(1)); ok {
+		t.Errorf("proposer found in empty validator set")
+	}
+}
+
+func TestPOSAPeriodBounds(t *testing.T) {
+	tests := []struct {
+		period uint64
+		warn   bool
+		err    error
+	}{
+		{3, false, nil},
+		{120, true, nil},
+		{100000, false, ErrPOSAPeriod},
+	}
+	for _, tt := range tests {
+		config := &ChainConfig{ChainID: big.NewInt(1), POSA: &POSAConfig{Period: tt.period, Epoch: 200}}
+		report := config.ValidateWithReport()
+		if err := report.Err(); !errors.Is(err, tt.err) {
+			t.Errorf("period %d: error mismatch: have %v, want %v", tt.period, err, tt.err)
+		}
+		if warned := len(report.Warnings) > 0 && strings.Contains(report.Warnings[0], "posa period"); warned != tt.warn {
+			t.Errorf("period %d: warning mismatch: have %v, want %v", tt.period, report.Warnings, tt.warn)
+		}
+	}
+	// Exotic chains may lift the limits
+	defer func(soft, hard uint64) { POSAPeriodSoftLimit, POSAPeriodHardLimit = soft, hard }(POSAPeriodSoftLimit, POSAPeriodHardLimit)
+	POSAPeriodSoftLimit, POSAPeriodHardLimit = 1000000, 1000000
+
+	config := &ChainConfig{ChainID: big.NewInt(1), POSA: &POSAConfig{Period: 100000, Epoch: 2}}
+	report := config.ValidateWithReport()
+	if err := report.Err(); err != nil {
+		t.Errorf("period within lifted limits rejected: %v", err)
+	}
+	for _, warning := range report.Warnings {
+		if strings.Contains(warning, "posa period") {
+			t.Errorf("period within lifted limits warned about: %v", warning)
+		}
+	}
+}
+
+func TestBerlinEIPPredicates(t *testing.T) {
+	for _, config := range []*ChainConfig{MainnetChainConfig, {BerlinBlock: big.NewInt(10)}, {}} {
+		for _, num := range []int64{0, 9, 10, 11171299} {
+			bnum := big.NewInt(num)
+			want := config.IsBerlin(bnum)
+			if config.IsEIP2929(bnum) != want || config.IsEIP2718(bnum) != want || config.IsEIP2930(bnum) != want {
+				t.Errorf("chain %v, block %d: Berlin EIPs disagree with IsBerlin (%v)", config.ChainID, num, want)
+			}
+		}
+	}
+}
+
+func TestHeadRules(t *testing.T) {
+	num := big.NewInt(11171299)
+	head := MainnetChainConfig.HeadRulesAt(num)
+	num.SetUint64(0) // must not affect the snapshot
+
+	if have := head.Number(); have.Cmp(big.NewInt(11171299)) != 0 {
+		t.Errorf("number mismatch: have %v, want 11171299", have)
+	}
+	if have := head.ChainID(); have.Cmp(MainnetChainConfig.ChainID) != 0 {
+		t.Errorf("chain ID mismatch: have %v, want %v", have, MainnetChainConfig.ChainID)
+	}
+	if !head.IsIshikari() || !head.IsBerlin() || !head.IsEIP155() {
+		t.Errorf("forks not active at mainnet head: %+v", head.Rules())
+	}
+	head.Number().SetUint64(1)
+	head.ChainID().SetUint64(1)
+	head.Rules().ChainID.SetUint64(1)
+	if head.Number().Cmp(big.NewInt(11171299)) != 0 || head.ChainID().Cmp(MainnetChainConfig.ChainID) != 0 {
+		t.Errorf("snapshot modified through its accessors")
+	}
+	if MainnetChainConfig.HeadRulesAt(big.NewInt(11171298)).IsIshikari() {
+		t.Errorf("ishikari active before its block")
+	}
+	nilHead := MainnetChainConfig.HeadRulesAt(nil)
+	if have := nilHead.Number(); have != nil {
+		t.Errorf("nil number mismatch: have %v, want nil", have)
+	}
+	if have := nilHead.ChainID(); have.Cmp(MainnetChainConfig.ChainID) != 0 {
+		t.Errorf("nil number chain ID mismatch: have %v, want %v", have, MainnetChainConfig.ChainID)
+	}
+	if nilHead.IsEIP155() != MainnetChainConfig.IsEIP155(nil) || nilHead.IsIshikari() {
+		t.Errorf("nil number rules mismatch: %+v", nilHead.Rules())
+	}
+}
+
+func TestPOSAManagersOptional(t *testing.T) {
+	config := MainnetChainConfig.Clone()
+	config.POSA.IshikariInitialManagers = nil
+	if err := config.Validate(); !errors.Is(err, ErrPOSANoValidators) {
+		t.Errorf("missing managers: have %v, want %v", err, ErrPOSANoValidators)
+	}
+	config.POSA.ManagersOptional = true
+	if err := config.Validate(); err != nil {
+		t.Fatalf("omitted optional managers rejected: %v", err)
+	}
+	validator := config.POSA.IshikariInitialValidators[1]
+	if manager, ok := config.POSA.ManagerForValidator(validator); !ok || manager != validator {
+		t.Errorf("validator not its own manager: have (%x, %v), want (%x, true)", manager, ok, validator)
+	}
+	// Provided managers must still line up with the validators
+	config.POSA.IshikariInitialManagers = MainnetChainConfig.POSA.IshikariInitialManagers[1:]
+	if err := config.Validate(); !errors.Is(err, ErrValidatorManagerMismatch) {
+		t.Errorf("mismatched managers: have %v, want %v", err, ErrValidatorManagerMismatch)
+	}
+}
+
+func TestValidateBlockTime(t *testing.T) {
+	posa := &ChainConfig{POSA: &POSAConfig{Period: 3, Epoch: 100}}
+	ethash := &ChainConfig{Ethash: new(EthashConfig)}
+	num := big.NewInt(10)
+
+	tests := []struct {
+		config    *ChainConfig
+		parent    uint64
+		block     uint64
+		wantError bool
+	}{
+		{posa, 100, 102, true},  // too fast
+		{posa, 100, 103, false}, // on time
+		{posa, 100, 110, false}, // late
+		{posa, 100, 99, true},   // backward
+		{posa, 100, 100, true},  // same second
+		{posa, math.MaxUint64 - 1, math.MaxUint64, true},
+		{ethash, 100, 101, false},
+		{ethash, 100, 100, true},
+		{ethash, 100, 99, true},
+	}
+	for i, tt := range tests {
+		err := tt.config.ValidateBlockTime(tt.parent, tt.block, num)
+		if (err != nil) != tt.wantError {
+			t.Errorf("test %d: error mismatch: have %v, want error %v", i, err, tt.wantError)
+		}
+	}
+}
+
+func TestExportForLightClient(t *testing.T) {
+	light := MainnetChainConfig.ExportForLightClient()
+	if len(light.POSA.IshikariInitialValidators) != 0 || len(light.POSA.IshikariInitialManagers) != 0 {
+		t.Errorf("validator set not trimmed: validators %v, managers %v", light.POSA.IshikariInitialValidators, light.POSA.IshikariInitialManagers)
+	}
+	if light.POSA.Period != MainnetChainConfig.POSA.Period || light.POSA.Epoch != MainnetChainConfig.POSA.Epoch {
+		t.Errorf("posa parameters mismatch: have period %d epoch %d, want period %d epoch %d",
+			light.POSA.Period, light.POSA.Epoch, MainnetChainConfig.POSA.Period, MainnetChainConfig.POSA.Epoch)
+	}
+	theirs := MainnetChainConfig.forks()
+	for i, fork := range light.forks() {
+		if !configNumEqual(*fork.block, *theirs[i].block) {
+			t.Errorf("%s fork mismatch: have %v, want %v", fork.name, *fork.block, *theirs[i].block)
+		}
+	}
+	if len(MainnetChainConfig.POSA.IshikariInitialValidators) == 0 {
+		t.Errorf("original config modified")
+	}
+	if err := light.ValidateForLightClient(); err != nil {
+		t.Errorf("light config rejected: %v", err)
+	}
+	if err := light.Validate(); !errors.Is(err, ErrPOSANoValidators) {
+		t.Errorf("light config full validation error mismatch: have %v, want %v", err, ErrPOSANoValidators)
+	}
+}
+
+func TestForkTransitions(t *testing.T) {
+	want := []ForkTransition{
+		{Name: "ishikari", Block: 11171299},
+		{Name: "ishikariPatch001", Block: 11171299},
+		{Name: "ishikariPatch002", Block: 11171299},
+	}
+	if have := MainnetChainConfig.ForkTransitions(11171000, 11172000); !reflect.DeepEqual(have, want) {
+		t.Errorf("transitions mismatch:\nhave %v\nwant %v", have, want)
+	}
+	// The range is exclusive at the start and inclusive at the end
+	if have := MainnetChainConfig.ForkTransitions(11171299, 11172000); len(have) != 0 {
+		t.Errorf("transitions at range start reported: %v", have)
+	}
+	if have := MainnetChainConfig.ForkTransitions(11171298, 11171299); !reflect.DeepEqual(have, want) {
+		t.Errorf("transitions at range end mismatch:\nhave %v\nwant %v", have, want)
+	}
+	// The synthetic CVE fork must be skipped
+	if have := MainnetChainConfig.ForkTransitions(2509000, 2510000); len(have) != 0 {
+		t.Errorf("synthetic fork reported: %v", have)
+	}
+}
+
+func TestExtraDataMinLength(t *testing.T) {
+	tests := []struct {
+		config *ChainConfig
+		num    int64
+		want   int
+	}{
+		{MainnetChainConfig, 0, 117},
+		{MainnetChainConfig, 11171298, 117}, // pre-Ishikari, with a validator
+		{MainnetChainConfig, 11171299, 97},  // post-Ishikari
+		{AllCliqueProtocolChanges, 100, 97},
+		{AllEthashProtocolChanges, 100, 0},
+	}
+	for i, tt := range tests {
+		if have := tt.config.ExtraDataMinLength(big.NewInt(tt.num)); have != tt.want {
+			t.Errorf("test %d: min length mismatch at %d: have %d, want %d", i, tt.num, have, tt.want)
+		}
+	}
+}
+
+func TestIsFeeExempt(t *testing.T) {
+	var (
+		exempt = common.HexToAddress("0x000000000000000000000000000000000000f555")
+		other  = common.HexToAddress("0x000000000000000000000000000000000000f556")
+	)
+	config := AllEthashProtocolChanges.Clone()
+	config.FeeExemptAddresses = []common.Address{exempt}
+
+	if !config.IsFeeExempt(exempt) {
+		t.Errorf("exempt address %x not exempt", exempt)
+	}
+	if config.IsFeeExempt(other) {
+		t.Errorf("address %x exempt", other)
+	}
+	if cpy := config.Clone(); !cpy.IsFeeExempt(exempt) || !cpy.Equal(config) {
+		t.Errorf("exempt addresses lost in clone")
+	}
+	// Lookups must not mutate the config
+	if cpy := config.Clone(); !reflect.DeepEqual(cpy, config) {
+		t.Errorf("config modified by lookups:\nhave %#v\nwant %#v", config, cpy)
+	}
+	// Decoded configs must answer from their lookup set, clones from their list
+	blob, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+	dec := new(ChainConfig)
+	if err := json.Unmarshal(blob, dec); err != nil {
+		t.Fatalf("failed to unmarshal config: %v", err)
+	}
+	if len(dec.feeExempt) != 1 || !dec.IsFeeExempt(exempt) || dec.IsFeeExempt(other) {
+		t.Errorf("decoded exempt addresses mismatch: %v", dec.feeExempt)
+	}
+	cpy := dec.Clone()
+	cpy.FeeExemptAddresses[0] = other
+	if cpy.IsFeeExempt(exempt) || !cpy.IsFeeExempt(other) || !dec.IsFeeExempt(exempt) {
+		t.Errorf("clone answered from a stale lookup set")
+	}
+	if !dec.Equal(config) {
+		t.Errorf("decoded config differs")
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("valid exempt addresses rejected: %v", err)
+	}
+	config.FeeExemptAddresses = append(config.FeeExemptAddresses, common.Address{})
+	if err := config.Validate(); err == nil {
+		t.Errorf("zero exempt address accepted")
+	}
+}
+
+func TestUpgradeForksAtomically(t *testing.T) {
+	config, err := MainnetChainConfig.UpgradeForksAtomically(map[string]*big.Int{
+		"london":        big.NewInt(20000000),
+		"mergeNetsplit": big.NewInt(20000000),
+	})
+	if err != nil {
+		t.Fatalf("valid upgrade rejected: %v", err)
+	}
+	if config.LondonBlock.Int64() != 20000000 || config.MergeNetsplitBlock.Int64() != 20000000 {
+		t.Errorf("upgrade not applied: london %v, mergeNetsplit %v", config.LondonBlock, config.MergeNetsplitBlock)
+	}
+	// Moving Ishikari before Berlin breaks the fork order, the valid change in
+	// the same set must not be applied either
+	before := MainnetChainConfig.Clone()
+	if _, err := MainnetChainConfig.UpgradeForksAtomically(map[string]*big.Int{
+		"london":   big.NewInt(20000000),
+		"berlin":   big.NewInt(20000000),
+		"ishikari": big.NewInt(11171299),
+	}); !errors.Is(err, ErrForkOrder) {
+		t.Errorf("order breaking upgrade error mismatch: have %v, want %v", err, ErrForkOrder)
+	}
+	if !MainnetChainConfig.Equal(before) {
+		t.Errorf("original config modified: %v", before.Diff(MainnetChainConfig))
+	}
+}
+
+// Tests the fork field convention "nil = no fork, 0 = already activated": every
+// fork scheduled alone must be inactive at any block when nil and active from
+// block 0 on when zero. Aliases falling back to other forks (Petersburg, London)
+// aren't exercised as those are unset here.
+func TestForkNilVsZeroSemantics(t *testing.T) {
+	// The CVE fork deliberately deviates: nil means the fix always applies and
+	// the boundary is exclusive. Both are consensus rules of mainnet and any
+	// chain without the field, see IsCVE202139137.
+	exempt := map[string]bool{"cve_2021_39137": true}
+
+	predicates := map[string]func(c *ChainConfig, num *big.Int) bool{
+		"homestead":      (*ChainConfig).IsHomestead,
+		"daoFork":        (*ChainConfig).IsDAOFork,
+		"eip150":         (*ChainConfig).IsEIP150,
+		"eip155":         (*ChainConfig).IsEIP155,
+		"eip158":         (*ChainConfig).IsEIP158,
+		"byzantium":      (*ChainConfig).IsByzantium,
+		"constantinople": (*ChainConfig).IsConstantinople,
+		"petersburg":     (*ChainConfig).IsPetersburg,
+		"istanbul":       (*ChainConfig).IsIstanbul,
+		"muirGlacier":    (*ChainConfig).IsMuirGlacier,
+		"berlin":         (*ChainConfig).IsBerlin,
+		"ishikari":       (*ChainConfig).IsKCCIshikari,
+		"ishikariPatch001": func(c *ChainConfig, num *big.Int) bool {
+			fork, _ := c.fork("ishikariPatch001")
+			return c.isForkActive(fork, num)
+		},
+		"ishikariPatch002": func(c *ChainConfig, num *big.Int) bool {
+			fork, _ := c.fork("ishikariPatch002")
+			return c.isForkActive(fork, num)
+		},
+		"london": (*ChainConfig).IsLondon,
+		"mergeNetsplit": func(c *ChainConfig, num *big.Int) bool {
+			c.TerminalTotalDifficulty = new(big.Int).Lsh(common.Big1, 128)
+			return c.IsPostMerge(num, nil)
+		},
+		"yoloV3": (*ChainConfig).IsBerlin,
+		"ewasm":  (*ChainConfig).IsEWASM,
+	}
+	for _, fork := range new(ChainConfig).forks() {
+		if exempt[fork.name] {
+			continue
+		}
+		active, ok := predicates[fork.name]
+		if !ok {
+			t.Errorf("fork %s: no predicate, extend the test", fork.name)
+			continue
+		}
+		for _, num := range []int64{0, 1, 1000000} {
+			unset := new(ChainConfig)
+			if active(unset, big.NewInt(num)) {
+				t.Errorf("fork %s: nil fork active at block %d", fork.name, num)
+			}
+			zero := new(ChainConfig)
+			f, _ := zero.fork(fork.name)
+			*f.block = big.NewInt(0)
+			if !active(zero, big.NewInt(num)) {
+				t.Errorf("fork %s: zero fork inactive at block %d", fork.name, num)
+			}
+		}
+	}
+	// Document the exempt behaviour, so that changing it fails loudly
+	cve := new(ChainConfig)
+	if !cve.IsCVE202139137(big.NewInt(0)) {
+		t.Errorf("nil cve_2021_39137 fork: fix not applied at genesis")
+	}
+	cve.CVE_2021_39137Block = big.NewInt(0)
+	if cve.IsCVE202139137(big.NewInt(0)) || !cve.IsCVE202139137(big.NewInt(1)) {
+		t.Errorf("zero cve_2021_39137 fork: fix must apply from block 1 on")
+	}
+}
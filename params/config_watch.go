@@ -0,0 +1,165 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// ConfigWatcher polls a chain config file (either a bare config or a genesis
+// file embedding one) and notifies the registered callbacks whenever the config
+// it contains changes. It is meant for tooling like config editors, nodes never
+// reload their chain config.
+type ConfigWatcher struct {
+	path     string
+	interval time.Duration
+
+	current   *ChainConfig
+	callbacks []func(old, new *ChainConfig)
+
+	quit chan struct{} // Closed to stop the poller, nil if not running
+	term chan struct{} // Closed when the poller terminated
+
+	lock sync.Mutex
+}
+
+// NewConfigWatcher creates a watcher polling the config file at path every
+// interval. The file is loaded once right away, failing if it's unreadable.
+func NewConfigWatcher(path string, interval time.Duration) (*ConfigWatcher, error) {
+	config, err := LoadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ConfigWatcher{
+		path:     path,
+		interval: interval,
+		current:  config,
+	}, nil
+}
+
+// OnChange registers a callback invoked with the old and the new config on every
+// change, use old.Diff(new) to find out what changed.
+func (w *ConfigWatcher) OnChange(fn func(old, new *ChainConfig)) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.callbacks = append(w.callbacks, fn)
+}
+
+// Current returns the last successfully loaded config.
+func (w *ConfigWatcher) Current() *ChainConfig {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.current
+}
+
+// Start starts polling the config file in the background. It's a no-op if the
+// watcher is already running.
+func (w *ConfigWatcher) Start() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.quit != nil {
+		return
+	}
+	w.quit, w.term = make(chan struct{}), make(chan struct{})
+	go w.loop(w.quit, w.term)
+}
+
+// Stop stops polling and waits for the background poller to terminate. It's a
+// no-op if the watcher isn't running.
+func (w *ConfigWatcher) Stop() {
+	w.lock.Lock()
+	quit, term := w.quit, w.term
+	w.quit, w.term = nil, nil
+	w.lock.Unlock()
+
+	if quit == nil {
+		return
+	}
+	close(quit)
+	<-term
+}
+
+// loop polls the config file until quit is closed, closing term on return.
+func (w *ConfigWatcher) loop(quit, term chan struct{}) {
+	defer close(term)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.Poll()
+		case <-quit:
+			return
+		}
+	}
+}
+
+// Poll reloads the config file once, invoking the callbacks if the config has
+// changed. Unreadable or malformed files, e.g. caught mid-write, are skipped
+// until fixed.
+func (w *ConfigWatcher) Poll() {
+	config, err := LoadConfigFile(w.path)
+	if err != nil {
+		return
+	}
+	w.lock.Lock()
+	old := w.current
+	if old.Equal(config) {
+		w.lock.Unlock()
+		return
+	}
+	w.current = config
+	callbacks := append([]func(old, new *ChainConfig){}, w.callbacks...)
+	w.lock.Unlock()
+
+	for _, fn := range callbacks {
+		fn(old, config)
+	}
+}
+
+// LoadConfigFile loads a chain config from a JSON file, which may either hold
+// the config itself or a genesis specification with the config in its "config"
+// field.
+func LoadConfigFile(path string) (*ChainConfig, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var genesis struct {
+		Config *ChainConfig `json:"config"`
+	}
+	if err := json.Unmarshal(blob, &genesis); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	if genesis.Config != nil {
+		return genesis.Config, nil
+	}
+	config := new(ChainConfig)
+	if err := json.Unmarshal(blob, config); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return config, nil
+}
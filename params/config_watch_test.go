@@ -0,0 +1,147 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfigWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "genesis.json")
+	write := func(config *ChainConfig) {
+		blob, err := json.Marshal(map[string]interface{}{"config": config, "gasLimit": "0x47b760"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, blob, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(MainnetChainConfig)
+
+	watcher, err := NewConfigWatcher(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	changes := make(chan []string, 1)
+	watcher.OnChange(func(old, new *ChainConfig) {
+		changes <- old.Diff(new)
+	})
+	watcher.Start()
+	defer watcher.Stop()
+
+	// Rewriting the same config must not fire, changing it must
+	write(MainnetChainConfig)
+	write(MainnetChainConfig.WithFork("ishikariPatch002", big.NewInt(12000000)))
+
+	select {
+	case diff := <-changes:
+		if want := []string{"ishikariPatch002Block: 11171299 -> 12000000"}; !reflect.DeepEqual(diff, want) {
+			t.Errorf("diff mismatch: have %v, want %v", diff, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("change not detected")
+	}
+	if have := watcher.Current().IshikariPatch002Block; have.Cmp(big.NewInt(12000000)) != 0 {
+		t.Errorf("current config not updated: have %v", have)
+	}
+	select {
+	case diff := <-changes:
+		t.Errorf("spurious change notification: %v", diff)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Changes outside the fork schedule must be reported too
+	updated := watcher.Current().Clone()
+	updated.MinClientVersions = map[string]string{"ishikari": "v1.2.0"}
+	write(updated)
+
+	select {
+	case diff := <-changes:
+		if want := []string{"minClientVersions: map[] -> map[ishikari:v1.2.0]"}; !reflect.DeepEqual(diff, want) {
+			t.Errorf("diff mismatch: have %v, want %v", diff, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("client version change not detected")
+	}
+}
+
+func TestConfigWatcherStartStop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	blob, err := json.Marshal(MainnetChainConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, blob, 0600); err != nil {
+		t.Fatal(err)
+	}
+	watcher, err := NewConfigWatcher(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	// Stopping an idle watcher, starting a running one and stopping a stopped
+	// one must all be no-ops
+	watcher.Stop()
+	watcher.Start()
+	watcher.Start()
+	watcher.Stop()
+	watcher.Stop()
+
+	// The watcher must be restartable
+	watcher.Start()
+	watcher.Stop()
+}
+
+func TestConfigDiff(t *testing.T) {
+	var (
+		old     = MainnetChainConfig
+		updated = old.WithChainID(big.NewInt(9999)).WithFork("ewasm", big.NewInt(100))
+	)
+	updated.POSA.Period = 5
+	updated.DisabledOpcodes = map[string][]string{"ishikari": {"SELFDESTRUCT"}}
+	want := []string{
+		"chainId: 126 -> 9999",
+		"ewasmBlock: <nil> -> 100",
+		"disabledOpcodes: map[] -> map[ishikari:[SELFDESTRUCT]]",
+		"engine: " + old.POSA.String() + " -> " + updated.POSA.String(),
+	}
+	if have := old.Diff(updated); !reflect.DeepEqual(have, want) {
+		t.Errorf("diff mismatch:\nhave %v\nwant %v", have, want)
+	}
+	if have := old.Diff(old.Clone()); len(have) != 0 {
+		t.Errorf("identical configs differ: %v", have)
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package paramsaccess exposes a few package-private details of params to its
+// test support package, params/paramstest, without exporting them from params.
+// The hooks are installed by params on initialization.
+package paramsaccess
+
+import "math/big"
+
+var (
+	// ForkBlock returns the address of the block field of the named fork in a
+	// *params.ChainConfig, or nil if there's no such fork.
+	ForkBlock func(config interface{}, name string) **big.Int
+
+	// OrderedForks returns the canonical names of the forks taking part in the
+	// fork ordering, in their required activation order.
+	OrderedForks func() []string
+
+	// IsUnorderedFork reports whether the named fork is exempt from the fork
+	// ordering.
+	IsUnorderedFork func(name string) bool
+)
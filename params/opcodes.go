@@ -0,0 +1,59 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import "strconv"
+
+// opcodeNames is the set of EVM opcode names a config may refer to. It mirrors
+// the names known to core/vm, which can't be imported here; TestConfigOpcodeNames
+// in core/vm keeps the two in sync.
+var opcodeNames = func() map[string]bool {
+	names := make(map[string]bool)
+	for _, name := range []string{
+		"STOP", "ADD", "MUL", "SUB", "DIV", "SDIV", "MOD", "SMOD", "EXP", "NOT",
+		"LT", "GT", "SLT", "SGT", "EQ", "ISZERO", "SIGNEXTEND",
+		"AND", "OR", "XOR", "BYTE", "SHL", "SHR", "SAR", "ADDMOD", "MULMOD",
+		"SHA3",
+		"ADDRESS", "BALANCE", "ORIGIN", "CALLER", "CALLVALUE",
+		"CALLDATALOAD", "CALLDATASIZE", "CALLDATACOPY", "CHAINID",
+		"DELEGATECALL", "STATICCALL", "CODESIZE", "CODECOPY", "GASPRICE",
+		"EXTCODESIZE", "EXTCODECOPY", "RETURNDATASIZE", "RETURNDATACOPY", "EXTCODEHASH",
+		"BLOCKHASH", "COINBASE", "TIMESTAMP", "NUMBER", "DIFFICULTY", "GASLIMIT", "SELFBALANCE",
+		"POP", "MLOAD", "MSTORE", "MSTORE8", "SLOAD", "SSTORE",
+		"JUMP", "JUMPI", "PC", "MSIZE", "GAS", "JUMPDEST",
+		"CREATE", "CREATE2", "CALL", "RETURN", "CALLCODE", "REVERT", "SELFDESTRUCT",
+	} {
+		names[name] = true
+	}
+	for i := 1; i <= 32; i++ {
+		names["PUSH"+strconv.Itoa(i)] = true
+	}
+	for i := 1; i <= 16; i++ {
+		names["DUP"+strconv.Itoa(i)] = true
+		names["SWAP"+strconv.Itoa(i)] = true
+	}
+	for i := 0; i <= 4; i++ {
+		names["LOG"+strconv.Itoa(i)] = true
+	}
+	return names
+}()
+
+// IsOpcodeName reports whether name is the name of an EVM opcode, as accepted
+// in the DisabledOpcodes of a chain config.
+func IsOpcodeName(name string) bool {
+	return opcodeNames[name]
+}
@@ -0,0 +1,142 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package paramstest provides test helpers for chain configs, for the tests of
+// this module as well as those of downstream forks.
+package paramstest
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/internal/paramsaccess"
+)
+
+// DeriveTestConfig clones base and replaces its POSA validators with accounts
+// deterministically derived from seed, so that tests control the signing keys.
+// Every derived validator is also its own manager. The fork schedule and all
+// the other engine parameters are preserved. The returned keys are in the same
+// order as the validators of the new config.
+func DeriveTestConfig(base *params.ChainConfig, seed int64) (*params.ChainConfig, []*ecdsa.PrivateKey) {
+	config := base.Clone()
+	if config.POSA == nil {
+		return config, nil
+	}
+	var (
+		keys       = make([]*ecdsa.PrivateKey, len(config.POSA.IshikariInitialValidators))
+		validators = make([]common.Address, len(keys))
+	)
+	for i := range keys {
+		keys[i] = deriveTestKey(seed, uint64(i))
+		validators[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	config.POSA.IshikariInitialValidators = validators
+	config.POSA.IshikariInitialManagers = append([]common.Address(nil), validators...)
+	return config, keys
+}
+
+// deriveTestKey deterministically derives the index-th private key from seed.
+func deriveTestKey(seed int64, index uint64) *ecdsa.PrivateKey {
+	blob := make([]byte, 16)
+	binary.BigEndian.PutUint64(blob, uint64(seed))
+	binary.BigEndian.PutUint64(blob[8:], index)
+
+	for digest := crypto.Keccak256(blob); ; digest = crypto.Keccak256(digest) {
+		// Rehash in the astronomically unlikely case of an invalid scalar
+		if key, err := crypto.ToECDSA(digest); err == nil {
+			return key
+		}
+	}
+}
+
+// rulesExemptForks are the forks which don't change the EVM rules and thus have
+// no Rules flag: the DAO fork and Muir Glacier only touch the state transition
+// and the difficulty, the Ishikari patches only the consensus engine, London and
+// the merge netsplit only the fee market and peering, and EWASM isn't wired up.
+var rulesExemptForks = map[string]bool{
+	"daoFork":          true,
+	"muirGlacier":      true,
+	"ishikariPatch001": true,
+	"ishikariPatch002": true,
+	"london":           true,
+	"mergeNetsplit":    true,
+	"ewasm":            true,
+}
+
+// AssertForkConsistency checks that every fork block field of ChainConfig is
+// wired up throughout package params, failing the test otherwise, to catch
+// missed spots when adding fork fields:
+//
+//   - every *big.Int field named "...Block" is registered as a fork,
+//   - every non-synthetic fork is part of the fork ordering (or explicitly exempt),
+//   - String reports every fork,
+//   - every fork changing the EVM rules is reflected in Rules (or explicitly exempt).
+func AssertForkConsistency(t testing.TB, cfg *params.ChainConfig) {
+	t.Helper()
+
+	var (
+		kind    = reflect.TypeOf(params.ChainConfig{})
+		ordered = make(map[string]bool)
+	)
+	for _, name := range paramsaccess.OrderedForks() {
+		ordered[name] = true
+	}
+	for i := 0; i < kind.NumField(); i++ {
+		field := kind.Field(i)
+		if !strings.HasSuffix(field.Name, "Block") || field.Type != reflect.TypeOf(new(big.Int)) {
+			continue
+		}
+		var (
+			key  = strings.Split(field.Tag.Get("json"), ",")[0]
+			name = strings.TrimSuffix(key, "Block")
+		)
+		// Every fork field must be registered, pointing to the field itself
+		cpy := cfg.Clone()
+		block := paramsaccess.ForkBlock(cpy, key)
+		if block == nil {
+			t.Errorf("fork field %s (%s) not registered in forks()", field.Name, key)
+			continue
+		}
+		if block != reflect.ValueOf(cpy).Elem().Field(i).Addr().Interface().(**big.Int) {
+			t.Errorf("fork %s registered with the wrong field, want %s", name, field.Name)
+		}
+		if !cfg.IsSyntheticFork(key) && !ordered[name] && !paramsaccess.IsUnorderedFork(name) {
+			t.Errorf("fork %s missing from the fork ordering", name)
+		}
+		// String must report the fork, check it with a block number used nowhere else
+		marker := new(big.Int).SetUint64(9876543210 + uint64(i))
+		*block = marker
+		if !strings.Contains(cpy.String(), marker.String()) {
+			t.Errorf("fork %s missing from String()", name)
+		}
+		// Scheduling the fork alone must flip some of the rules across its block
+		if rulesExemptForks[name] {
+			continue
+		}
+		single := &params.ChainConfig{ChainID: cfg.ChainID}
+		*paramsaccess.ForkBlock(single, key) = big.NewInt(100)
+		if reflect.DeepEqual(single.Rules(big.NewInt(99)), single.Rules(big.NewInt(101))) {
+			t.Errorf("fork %s not reflected in Rules", name)
+		}
+	}
+}
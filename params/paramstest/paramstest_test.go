@@ -0,0 +1,65 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package paramstest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestDeriveTestConfig(t *testing.T) {
+	config, keys := DeriveTestConfig(params.MainnetChainConfig, 1)
+
+	if err := config.Validate(); err != nil {
+		t.Fatalf("derived config invalid: %v", err)
+	}
+	if len(keys) != len(params.MainnetChainConfig.POSA.IshikariInitialValidators) {
+		t.Fatalf("key count mismatch: have %d, want %d", len(keys), len(params.MainnetChainConfig.POSA.IshikariInitialValidators))
+	}
+	for i, key := range keys {
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		if config.POSA.IshikariInitialValidators[i] != addr {
+			t.Errorf("validator %d mismatch: have %x, want %x", i, config.POSA.IshikariInitialValidators[i], addr)
+		}
+		if config.POSA.IshikariInitialManagers[i] != addr {
+			t.Errorf("manager %d mismatch: have %x, want %x", i, config.POSA.IshikariInitialManagers[i], addr)
+		}
+	}
+	if config.IshikariBlock.Cmp(params.MainnetChainConfig.IshikariBlock) != 0 || config.POSA.Period != 3 || config.POSA.Epoch != 100 {
+		t.Errorf("fork schedule or engine parameters not preserved: %v", config)
+	}
+	if params.MainnetChainConfig.POSA.IshikariInitialValidators[0] == config.POSA.IshikariInitialValidators[0] {
+		t.Errorf("base config validators modified")
+	}
+	// The derivation must be deterministic and depend on the seed
+	again, _ := DeriveTestConfig(params.MainnetChainConfig, 1)
+	if !reflect.DeepEqual(again, config) {
+		t.Errorf("derivation not deterministic")
+	}
+	other, _ := DeriveTestConfig(params.MainnetChainConfig, 2)
+	if reflect.DeepEqual(other.POSA.IshikariInitialValidators, config.POSA.IshikariInitialValidators) {
+		t.Errorf("different seeds derived the same validators")
+	}
+}
+
+func TestForkConsistency(t *testing.T) {
+	AssertForkConsistency(t, params.MainnetChainConfig)
+	AssertForkConsistency(t, params.TestnetChainConfig)
+}
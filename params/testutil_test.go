@@ -0,0 +1,134 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"encoding/json"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Generate implements testing/quick.Generator, producing random chain configs
+// with a valid fork order and, half of the time, a valid POSA engine. It allows
+// fuzzing the config serializers with quick.Check.
+func (*ChainConfig) Generate(rand *rand.Rand, size int) reflect.Value {
+	config := &ChainConfig{
+		ChainID:        big.NewInt(1 + rand.Int63n(1<<32)),
+		DAOForkSupport: rand.Intn(2) == 0,
+		ConfigVersion:  ConfigSchemaVersion,
+	}
+	rand.Read(config.EIP150Hash[:])
+
+	// Schedule a random prefix of the mandatory forks in ascending order, with
+	// the optional ones randomly left out
+	var (
+		block = uint64(0)
+		next  = func() *big.Int {
+			block += uint64(rand.Intn(size + 1))
+			return new(big.Int).SetUint64(block)
+		}
+		ordered = []struct {
+			field    **big.Int
+			optional bool
+		}{
+			{&config.HomesteadBlock, false},
+			{&config.DAOForkBlock, true},
+			{&config.EIP150Block, false},
+			{&config.EIP155Block, false},
+			{&config.EIP158Block, false},
+			{&config.ByzantiumBlock, false},
+			{&config.ConstantinopleBlock, false},
+			{&config.PetersburgBlock, false},
+			{&config.IstanbulBlock, false},
+			{&config.MuirGlacierBlock, true},
+			{&config.BerlinBlock, false},
+		}
+		scheduled = rand.Intn(len(ordered) + 1)
+	)
+	for i, fork := range ordered[:scheduled] {
+		if !fork.optional || rand.Intn(2) == 0 || i == scheduled-1 {
+			*fork.field = next()
+		}
+	}
+	if config.BerlinBlock != nil && rand.Intn(2) == 0 {
+		config.YoloV3Block = new(big.Int).Set(config.BerlinBlock)
+	}
+	if rand.Intn(2) == 0 {
+		config.CVE_2021_39137Block = big.NewInt(rand.Int63n(1 << 32))
+	}
+	if rand.Intn(2) == 0 {
+		denom, elasticity := uint64(1+rand.Intn(16)), uint64(1+rand.Intn(4))
+		config.BaseFeeChangeDenominator, config.ElasticityMultiplier = &denom, &elasticity
+	}
+	// Pick a random engine, scheduling the Ishikari forks at an epoch end for POSA
+	switch rand.Intn(3) {
+	case 0:
+		config.Ethash = new(EthashConfig)
+	case 1:
+		config.Clique = &CliqueConfig{Period: uint64(rand.Intn(30)), Epoch: uint64(1 + rand.Intn(30000))}
+	default:
+		validators := make([]common.Address, 1+rand.Intn(size+1))
+		managers := make([]common.Address, len(validators))
+		for i := range validators {
+			rand.Read(validators[i][:])
+			rand.Read(managers[i][:])
+		}
+		config.POSA = &POSAConfig{
+			Period:                    uint64(1 + rand.Intn(10)),
+			Epoch:                     uint64(len(validators) + 1 + rand.Intn(size+1)),
+			IshikariInitialValidators: validators,
+			IshikariInitialManagers:   managers,
+		}
+		rand.Read(config.POSA.IshikariAdminMultiSig[:])
+
+		if config.BerlinBlock != nil && rand.Intn(2) == 0 {
+			epoch := config.POSA.Epoch
+			config.IshikariBlock = new(big.Int).SetUint64((block/epoch+1)*epoch - 1)
+			config.IshikariPatch001Block = new(big.Int).Add(config.IshikariBlock, big.NewInt(rand.Int63n(int64(size)+1)))
+			config.IshikariPatch002Block = new(big.Int).Add(config.IshikariPatch001Block, big.NewInt(rand.Int63n(int64(size)+1)))
+		}
+	}
+	return reflect.ValueOf(config)
+}
+
+func TestGenerateJSONRoundTrip(t *testing.T) {
+	roundtrip := func(config *ChainConfig) bool {
+		if err := config.Validate(); err != nil {
+			t.Logf("invalid config generated: %v", err)
+			return false
+		}
+		blob, err := json.Marshal(config)
+		if err != nil {
+			t.Logf("failed to marshal config: %v", err)
+			return false
+		}
+		dec := new(ChainConfig)
+		if err := json.Unmarshal(blob, dec); err != nil {
+			t.Logf("failed to unmarshal config: %v", err)
+			return false
+		}
+		return dec.Equal(config)
+	}
+	if err := quick.Check(roundtrip, nil); err != nil {
+		t.Error(err)
+	}
+}